@@ -14,22 +14,308 @@ type GithubIssueSpec struct {
 	//title of the github issue
 	Title string `json:"title"`
 
-	// +kubebuilder:validation:Pattern=^[a-zA-Z0-9]+[\-]?[a-zA-Z0-9]+\/[a-zA-Z0-9\.\-_]+$
-	Repo string `json:"repo"` //EXPECTED: owner/repo
+	// +kubebuilder:validation:Pattern=`^([a-zA-Z0-9]+[\-]?[a-zA-Z0-9]+(\/[a-zA-Z0-9\.\-_]+)+|[A-Z][A-Z0-9_]+)$`
+	Repo string `json:"repo"` //EXPECTED: owner/repo (group/subgroup/project on GitLab, the project key on Jira)
+	//issue tracker hosting the repo: GitHub (default), GitLab, Gitea (Gitea and Forgejo) or Jira. Other trackers than
+	//GitHub need a credentialsRef holding an access token, and do not support milestoneRef and stateReason.
+	//With GitLab, baseURL defaults to https://gitlab.com/api/v4/.
+	//With Gitea, baseURL is required (e.g. https://gitea.example.com/api/v1/), and deletionPolicy Lock is not supported.
+	//With Jira, baseURL is required and is the site (e.g. https://example.atlassian.net), the credentials Secret also
+	//holds the account's email under "username" on Jira Cloud, see spec.jira for the rest. Deletion policy Lock is not
+	//supported, and as Jira issues have a single assignee the assignees after the first are rejected
+	// +kubebuilder:validation:Enum=GitHub;GitLab;Gitea;Jira
+	// +kubebuilder:default=GitHub
+	// +optional
+	Provider Provider `json:"provider,omitempty"`
+	//how the issue maps onto Jira, only used with provider Jira
+	// +optional
+	Jira *JiraSpec `json:"jira,omitempty"`
 	//description of the github issue
 	Desc string `json:"description"`
+	//number of an existing github issue to bind this object to, instead of creating a new one. An issue owned by
+	//another GithubIssue object is only taken over with adoptionPolicy Always
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	IssueNumber int `json:"issueNumber,omitempty"`
+	//which existing github issues the operator may take over when it has no issue yet. The operator marks the body of
+	//every issue it manages with a hidden ownership marker and never modifies an issue it does not own.
+	//Never (default): only issues created by the operator for this object, or named by spec.issueNumber.
+	//TitleMatch: also an issue with the same title that no other GithubIssue object owns.
+	//Always: also issues owned by other GithubIssue objects.
+	// +kubebuilder:validation:Enum=Never;TitleMatch;Always
+	// +kubebuilder:default=Never
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	//labels of the github issue
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	//Authoritative: the issue's labels are exactly spec.labels. OwnedOnly: only labels the operator added are removed,
+	//labels added by humans are left alone
+	// +kubebuilder:validation:Enum=Authoritative;OwnedOnly
+	// +kubebuilder:default=Authoritative
+	// +optional
+	LabelPolicy LabelPolicy `json:"labelPolicy,omitempty"`
+	//github logins of the users the issue is assigned to
+	// +optional
+	Assignees []string `json:"assignees,omitempty"`
+	//GithubMilestone (in this object's namespace, same repo) the issue belongs to. The issue waits with the
+	//MilestoneResolved condition until the milestone exists on github
+	// +optional
+	MilestoneRef *LocalObjectReference `json:"milestoneRef,omitempty"`
+	//desired state of the github issue. The controller reopens an issue closed by hand and closes an issue when this is set to closed
+	// +kubebuilder:validation:Enum=open;closed
+	// +kubebuilder:default=open
+	// +optional
+	State string `json:"state,omitempty"`
+	//why the issue is closed, only used when state is closed
+	// +kubebuilder:validation:Enum=completed;not_planned
+	// +optional
+	StateReason string `json:"stateReason,omitempty"`
+	//what happens to the github issue when this object is deleted:
+	//Close (default) closes it, Retain leaves it untouched, Lock closes it and locks the conversation,
+	//CommentAndClose posts spec.deletionComment on it and closes it
+	// +kubebuilder:validation:Enum=Close;Retain;Lock;CommentAndClose
+	// +kubebuilder:default=Close
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	//comment posted on the github issue by the CommentAndClose deletion policy
+	// +optional
+	DeletionComment string `json:"deletionComment,omitempty"`
+	//which side wins for the title, description, state, labels and assignees of the issue:
+	//KubernetesToGitHub (default): the spec is applied to github, edits made on github are overwritten.
+	//GitHubToKubernetes: edits made on github are copied into the spec, the operator does not modify the issue.
+	//Bidirectional: the side that changed since the last sync wins; if both changed the SyncConflict condition is set
+	//and neither side is modified until they agree again
+	// +kubebuilder:validation:Enum=KubernetesToGitHub;GitHubToKubernetes;Bidirectional
+	// +kubebuilder:default=KubernetesToGitHub
+	// +optional
+	SyncDirection SyncDirection `json:"syncDirection,omitempty"`
+	//what happens when the issue was edited on github and differs from the spec:
+	//Enforce (default): the spec is applied to the issue again.
+	//Report: the issue is left alone and the differences are recorded in status.drift and the Drifted condition
+	// +kubebuilder:validation:Enum=Enforce;Report
+	// +kubebuilder:default=Enforce
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	//Secret in this object's namespace holding the github credentials to use: either a token, or the appID,
+	//privateKey and (optionally) installationID of a Github App. Defaults to the operator-wide credentials, which
+	//are only sent to the operator-wide endpoint: credentialsRef is required with any other baseURL
+	// +optional
+	CredentialsRef *CredentialsRef `json:"credentialsRef,omitempty"`
+	//API URL of the Github Enterprise Server hosting the repo, e.g. https://github.example.com/api/v3/.
+	//Defaults to the operator-wide endpoint (github.com unless configured otherwise). A CA bundle for it can be
+	//put under the "ca.crt" key of the credentials Secret
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+	//upload API URL of the Github Enterprise Server, derived from baseURL if empty
+	// +optional
+	UploadURL string `json:"uploadURL,omitempty"`
 }
 
+// CredentialsRef points to the key of a Secret in the GithubIssue's namespace
+type CredentialsRef struct {
+	//name of the Secret
+	Name string `json:"name"`
+	//key of the token in the Secret's data (default "token")
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// Provider is the issue tracker hosting the repo of a GithubIssue
+type Provider string
+
+const (
+	ProviderGitHub Provider = "GitHub"
+	ProviderGitLab Provider = "GitLab"
+	ProviderGitea  Provider = "Gitea"
+	ProviderJira   Provider = "Jira"
+)
+
+// JiraSpec configures the Jira issue of a GithubIssue. The title is the issue's summary, the description (converted
+// from Markdown) its description, and spec.assignees holds at most one account ID (Cloud) or username (Server)
+type JiraSpec struct {
+	//Cloud (default): Jira Cloud, descriptions are sent in the Atlassian Document Format.
+	//Server: Jira Server or Data Center, descriptions are sent in wiki markup
+	// +kubebuilder:validation:Enum=Cloud;Server
+	// +kubebuilder:default=Cloud
+	// +optional
+	Deployment JiraDeployment `json:"deployment,omitempty"`
+	//issue type of the created issue (default Task)
+	// +optional
+	IssueType string `json:"issueType,omitempty"`
+	//workflow transition (or the status it leads to) applied when state is closed (default Done)
+	// +optional
+	CloseTransition string `json:"closeTransition,omitempty"`
+	//workflow transition (or the status it leads to) applied when state is open again (default "To Do")
+	// +optional
+	ReopenTransition string `json:"reopenTransition,omitempty"`
+}
+
+// JiraDeployment is the kind of Jira installation
+type JiraDeployment string
+
+const (
+	JiraDeploymentCloud  JiraDeployment = "Cloud"
+	JiraDeploymentServer JiraDeployment = "Server"
+)
+
+// DeletionPolicy decides what happens to the github issue when the GithubIssue object is deleted
+type DeletionPolicy string
+
+const (
+	DeletionPolicyClose           DeletionPolicy = "Close"
+	DeletionPolicyRetain          DeletionPolicy = "Retain"
+	DeletionPolicyLock            DeletionPolicy = "Lock"
+	DeletionPolicyCommentAndClose DeletionPolicy = "CommentAndClose"
+)
+
+// AdoptionPolicy decides which existing github issues a GithubIssue object may take over
+type AdoptionPolicy string
+
+const (
+	AdoptionPolicyNever      AdoptionPolicy = "Never"
+	AdoptionPolicyTitleMatch AdoptionPolicy = "TitleMatch"
+	AdoptionPolicyAlways     AdoptionPolicy = "Always"
+)
+
+// SyncDirection decides in which direction the issue and the GithubIssue object are kept in sync
+type SyncDirection string
+
+const (
+	SyncDirectionKubernetesToGitHub SyncDirection = "KubernetesToGitHub"
+	SyncDirectionGitHubToKubernetes SyncDirection = "GitHubToKubernetes"
+	SyncDirectionBidirectional      SyncDirection = "Bidirectional"
+)
+
+// DriftPolicy decides whether differences between the spec and the issue are fixed or reported
+type DriftPolicy string
+
+const (
+	DriftPolicyEnforce DriftPolicy = "Enforce"
+	DriftPolicyReport  DriftPolicy = "Report"
+)
+
+// FieldDrift is a field of the issue that differs from the spec
+type FieldDrift struct {
+	//title, description, labels, state or assignees
+	Field string `json:"field"`
+	//the value in the spec (the first differing line for the description)
+	Spec string `json:"spec"`
+	//the value on github (the first differing line for the description)
+	GitHub string `json:"github"`
+}
+
+// LabelPolicy decides which labels of the github issue the operator manages
+type LabelPolicy string
+
+const (
+	LabelPolicyAuthoritative LabelPolicy = "Authoritative"
+	LabelPolicyOwnedOnly     LabelPolicy = "OwnedOnly"
+)
+
 // GithubIssueStatus defines the observed state of GithubIssue
 type GithubIssueStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	State               string `json:"state,omitempty"`
 	LastUpdateTimestamp string `json:"lastUpdateTimestamp,omitempty"`
+	//number of the github issue this object is bound to (0 until the issue is created or adopted)
+	Number int `json:"number,omitempty"`
+	//GraphQL node ID of the github issue
+	NodeID string `json:"nodeID,omitempty"`
+	//key of the issue on trackers naming issues by key (Jira: PROJECT-123)
+	Key string `json:"key,omitempty"`
+	//link to the github issue in the browser
+	HTMLURL string `json:"htmlURL,omitempty"`
+	//labels the operator added to the github issue (see LabelPolicyOwnedOnly)
+	ManagedLabels []string `json:"managedLabels,omitempty"`
+	//number of the github milestone the operator assigned the issue to (see spec.milestoneRef)
+	Milestone int `json:"milestone,omitempty"`
+	//fields of the issue that differ from the spec, only recorded with driftPolicy Report
+	Drift []FieldDrift `json:"drift,omitempty"`
+	//hash of the title, description, state, labels and assignees both sides agreed on at the last sync
+	LastAppliedHash string `json:"lastAppliedHash,omitempty"`
+	//when the issue was last updated on github as of the last sync. With syncDirection Bidirectional only an issue
+	//updated later counts as edited on github
+	LastSyncedAt *metav1.Time `json:"lastSyncedAt,omitempty"`
+	//assignees from spec.assignees github refused to assign (e.g. not a collaborator of the repo)
+	RejectedAssignees []string `json:"rejectedAssignees,omitempty"`
+	//number of the issue the deletion comment (deletionPolicy CommentAndClose) was posted on, so a retried deletion
+	//does not post it again
+	DeletionCommentPosted int `json:"deletionCommentPosted,omitempty"`
+	//spec.assignees as of their last validation, they are only validated again once spec.assignees changes
+	ValidatedAssignees []string `json:"validatedAssignees,omitempty"`
+	//latest observations of the object's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	//the metadata.generation the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
+const (
+	// ConditionReady is True when the github issue exists and matches the spec
+	ConditionReady = "Ready"
+	// ConditionSynced is True when the last reconcile applied the spec to github, False with the failing step as reason
+	ConditionSynced = "Synced"
+	// ConditionGitHubReachable is False when github could not be reached (network errors, github server errors)
+	ConditionGitHubReachable = "GitHubReachable"
+	// ConditionAdopted is True when the object was bound to an issue that already existed on github,
+	// False when the operator created the issue
+	ConditionAdopted = "Adopted"
+	// ConditionIssueListTruncated is True when the repository has more issues than the operator is allowed to list,
+	// so an issue that is not bound by number yet could not be searched for in the entire repository.
+	ConditionIssueListTruncated = "IssueListTruncated"
+	// ConditionMilestoneResolved is True when spec.milestoneRef points to a milestone that exists on github, False while
+	// the issue waits for it. Absent without spec.milestoneRef
+	ConditionMilestoneResolved = "MilestoneResolved"
+	// ConditionSyncConflict is True when syncDirection is Bidirectional and both the spec and the issue changed since
+	// the last sync. Neither side is modified until they agree again
+	ConditionSyncConflict = "SyncConflict"
+	// ConditionDrifted is True when driftPolicy is Report and the issue differs from the spec, see status.drift
+	ConditionDrifted = "Drifted"
+
+	ReasonMaxIssuesReached = "MaxIssuesReached"
+	ReasonAllIssuesListed  = "AllIssuesListed"
+
+	ReasonIssueSynced       = "IssueSynced"
+	ReasonLookupFailed      = "LookupFailed"
+	ReasonCreateFailed      = "CreateFailed"
+	ReasonUpdateFailed      = "UpdateFailed"
+	ReasonDeletionFailed    = "DeletionFailed"
+	ReasonConnected         = "Connected"
+	ReasonConnectionFailed  = "ConnectionFailed"
+	ReasonGitHubServerError = "GitHubServerError"
+	ReasonTitleMatch        = "TitleMatch"
+	ReasonIssueNumber       = "IssueNumber"
+	ReasonOwnershipMarker   = "OwnershipMarker"
+	ReasonNotOwned          = "NotOwned"
+	ReasonBothSidesChanged  = "BothSidesChanged"
+	ReasonNoConflict        = "NoConflict"
+	ReasonDriftDetected     = "DriftDetected"
+	ReasonNoDrift           = "NoDrift"
+	ReasonIssueCreated      = "IssueCreated"
+	ReasonCredentialsError  = "CredentialsError"
+	// ReasonCredentialsRefRequired is set when spec.baseURL points away from the operator-wide endpoint without
+	// spec.credentialsRef. The object is not retried until its spec changes
+	ReasonCredentialsRefRequired = "CredentialsRefRequired"
+	// ReasonInvalidSpec is set when the spec asks for what spec.provider can not do. The object is not retried until
+	// its spec changes
+	ReasonInvalidSpec = "InvalidSpec"
+
+	ReasonMilestoneResolved     = "MilestoneResolved"
+	ReasonMilestoneNotFound     = "MilestoneNotFound"
+	ReasonMilestoneNotReady     = "MilestoneNotReady"
+	ReasonMilestoneRepoMismatch = "MilestoneRepoMismatch"
+)
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repo`
+//+kubebuilder:printcolumn:name="Number",type=integer,JSONPath=`.status.number`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 
 // GithubIssue is the Schema for the githubissues API
 type GithubIssue struct {
@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GithubIssueCommentSpec defines the desired state of GithubIssueComment
+type GithubIssueCommentSpec struct {
+	//the GithubIssue (in this object's namespace) whose github issue the comment is posted on
+	IssueRef LocalObjectReference `json:"issueRef"`
+	//body of the comment (markdown)
+	Body string `json:"body"`
+	//what happens to the comment when this object is deleted: Delete (default) deletes it,
+	//Minimize hides it as outdated, Retain leaves it untouched
+	// +kubebuilder:validation:Enum=Delete;Minimize;Retain
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy CommentDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// LocalObjectReference points to an object in the same namespace
+type LocalObjectReference struct {
+	//name of the object
+	Name string `json:"name"`
+}
+
+// CommentDeletionPolicy decides what happens to the github comment when the GithubIssueComment object is deleted
+type CommentDeletionPolicy string
+
+const (
+	CommentDeletionPolicyDelete   CommentDeletionPolicy = "Delete"
+	CommentDeletionPolicyMinimize CommentDeletionPolicy = "Minimize"
+	CommentDeletionPolicyRetain   CommentDeletionPolicy = "Retain"
+)
+
+// GithubIssueCommentStatus defines the observed state of GithubIssueComment
+type GithubIssueCommentStatus struct {
+	//ID of the github comment (0 until the comment is posted)
+	CommentID int64 `json:"commentID,omitempty"`
+	//GraphQL node ID of the github comment
+	NodeID string `json:"nodeID,omitempty"`
+	//link to the github comment in the browser
+	HTMLURL string `json:"htmlURL,omitempty"`
+	//owner/repo of the issue the comment was posted on
+	Repo string `json:"repo,omitempty"`
+	//number of the issue the comment was posted on
+	IssueNumber int `json:"issueNumber,omitempty"`
+	//hash of the body last written to the github comment
+	AppliedBodyHash string `json:"appliedBodyHash,omitempty"`
+	//latest observations of the object's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	//the metadata.generation the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+const (
+	ReasonCommentPosted = "CommentPosted"
+	ReasonIssueNotFound = "IssueNotFound"
+	ReasonIssueNotReady = "IssueNotReady"
+	// ReasonProviderNotSupported is set when the GithubIssue is not on github (see GithubIssueSpec.Provider)
+	ReasonProviderNotSupported = "ProviderNotSupported"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Issue",type=string,JSONPath=`.spec.issueRef.name`
+//+kubebuilder:printcolumn:name="Comment",type=integer,JSONPath=`.status.commentID`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// GithubIssueComment is the Schema for the githubissuecomments API
+type GithubIssueComment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubIssueCommentSpec   `json:"spec,omitempty"`
+	Status GithubIssueCommentStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubIssueCommentList contains a list of GithubIssueComment
+type GithubIssueCommentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubIssueComment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubIssueComment{}, &GithubIssueCommentList{})
+}
@@ -0,0 +1,83 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GithubLabelSpec defines the desired state of GithubLabel
+type GithubLabelSpec struct {
+	// +kubebuilder:validation:Pattern=^[a-zA-Z0-9]+[\-]?[a-zA-Z0-9]+\/[a-zA-Z0-9\.\-_]+$
+	Repo string `json:"repo"` //EXPECTED: owner/repo
+	//name of the label. Renaming keeps the label on the issues it is on. A label with this name that already exists
+	//in the repo is adopted, and left in the repo when the object is deleted
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	//color of the label, 6 hex digits without the leading #
+	// +kubebuilder:validation:Pattern=`^[0-9a-fA-F]{6}$`
+	Color string `json:"color"`
+	//description of the label
+	// +optional
+	Description string `json:"description,omitempty"`
+	//Secret in this object's namespace holding the github credentials to use, see GithubIssueSpec.CredentialsRef
+	// +optional
+	CredentialsRef *CredentialsRef `json:"credentialsRef,omitempty"`
+	//API URL of the Github Enterprise Server hosting the repo, see GithubIssueSpec.BaseURL
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+	//upload API URL of the Github Enterprise Server, derived from baseURL if empty
+	// +optional
+	UploadURL string `json:"uploadURL,omitempty"`
+}
+
+// GithubLabelStatus defines the observed state of GithubLabel
+type GithubLabelStatus struct {
+	//owner/repo the label was created in
+	Repo string `json:"repo,omitempty"`
+	//name of the label on github, differs from spec.name until a rename is applied
+	Name string `json:"name,omitempty"`
+	//GraphQL node ID of the github label
+	NodeID string `json:"nodeID,omitempty"`
+	//true if the operator created the label. Only such labels are deleted (when the object is deleted or spec.repo
+	//changes), a label that existed before is adopted and left in the repo
+	Created bool `json:"created,omitempty"`
+	//latest observations of the object's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	//the metadata.generation the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+const (
+	ReasonLabelSynced = "LabelSynced"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repo`
+//+kubebuilder:printcolumn:name="Label",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="Color",type=string,JSONPath=`.spec.color`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// GithubLabel is the Schema for the githublabels API
+type GithubLabel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubLabelSpec   `json:"spec,omitempty"`
+	Status GithubLabelStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubLabelList contains a list of GithubLabel
+type GithubLabelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubLabel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubLabel{}, &GithubLabelList{})
+}
@@ -0,0 +1,91 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GithubMilestoneSpec defines the desired state of GithubMilestone
+type GithubMilestoneSpec struct {
+	// +kubebuilder:validation:Pattern=^[a-zA-Z0-9]+[\-]?[a-zA-Z0-9]+\/[a-zA-Z0-9\.\-_]+$
+	Repo string `json:"repo"` //EXPECTED: owner/repo
+	//title of the milestone. A milestone with this title that already exists in the repo is adopted, and left in the
+	//repo when the object is deleted
+	// +kubebuilder:validation:MinLength=1
+	Title string `json:"title"`
+	//description of the milestone
+	// +optional
+	Description string `json:"description,omitempty"`
+	//due date of the milestone, YYYY-MM-DD
+	// +kubebuilder:validation:Pattern=`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`
+	// +optional
+	DueOn string `json:"dueOn,omitempty"`
+	//desired state of the milestone
+	// +kubebuilder:validation:Enum=open;closed
+	// +kubebuilder:default=open
+	// +optional
+	State string `json:"state,omitempty"`
+	//Secret in this object's namespace holding the github credentials to use, see GithubIssueSpec.CredentialsRef
+	// +optional
+	CredentialsRef *CredentialsRef `json:"credentialsRef,omitempty"`
+	//API URL of the Github Enterprise Server hosting the repo, see GithubIssueSpec.BaseURL
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+	//upload API URL of the Github Enterprise Server, derived from baseURL if empty
+	// +optional
+	UploadURL string `json:"uploadURL,omitempty"`
+}
+
+// GithubMilestoneStatus defines the observed state of GithubMilestone
+type GithubMilestoneStatus struct {
+	//owner/repo the milestone was created in
+	Repo string `json:"repo,omitempty"`
+	//number of the github milestone (0 until the milestone is created or adopted)
+	Number int `json:"number,omitempty"`
+	//GraphQL node ID of the github milestone
+	NodeID string `json:"nodeID,omitempty"`
+	//link to the github milestone in the browser
+	HTMLURL string `json:"htmlURL,omitempty"`
+	//true if the operator created the milestone. Only such milestones are deleted (when the object is deleted or
+	//spec.repo changes), a milestone that existed before is adopted and left in the repo
+	Created bool `json:"created,omitempty"`
+	//latest observations of the object's state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	//the metadata.generation the status reflects
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+const (
+	ReasonMilestoneSynced = "MilestoneSynced"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repo`
+//+kubebuilder:printcolumn:name="Number",type=integer,JSONPath=`.status.number`
+//+kubebuilder:printcolumn:name="Due",type=string,JSONPath=`.spec.dueOn`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+
+// GithubMilestone is the Schema for the githubmilestones API
+type GithubMilestone struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GithubMilestoneSpec   `json:"spec,omitempty"`
+	Status GithubMilestoneStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GithubMilestoneList contains a list of GithubMilestone
+type GithubMilestoneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GithubMilestone `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GithubMilestone{}, &GithubMilestoneList{})
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+)
+
+/*
+make the assignees of the issue match spec.assignees. Assignees the tracker rejects (e.g. users that are not
+collaborators of the repo) are recorded in status.rejectedAssignees instead of failing the reconcile. They are only
+validated again once spec.assignees changes, so a resync does not ask the tracker about every assignee again.
+written is true if the issue was modified
+*/
+func updateAssignees(issueTracker tracker.IssueTracker, ctx context.Context, issue *tracker.Issue, ghissue *g.GithubIssue) (written bool, err error) {
+	repo := ghissue.Spec.Repo
+	validated := isAssigneesValidated(ghissue)
+	desired := desiredAssignees(ghissue)
+	toAdd := missingNames(desired, issue.Assignees)
+	toRemove := missingNames(issue.Assignees, desired)
+
+	if !validated {
+		var rejected []string
+		toAdd, rejected, err = issueTracker.ValidateAssignees(ctx, repo, toAdd)
+		if err != nil {
+			return false, err
+		}
+		ghissue.Status.RejectedAssignees = rejected
+		ghissue.Status.ValidatedAssignees = ghissue.Spec.Assignees
+	}
+	if len(toAdd) > 0 {
+		err = issueTracker.AddAssignees(ctx, repo, issue.Number, toAdd)
+		if err != nil {
+			return false, err
+		}
+		written = true
+	}
+	if len(toRemove) > 0 {
+		err = issueTracker.RemoveAssignees(ctx, repo, issue.Number, toRemove)
+		if err != nil {
+			return written, err
+		}
+		written = true
+	}
+	return written, nil
+}
+
+/*
+the issue has the assignees of the spec, except for the rejected ones. Once spec.assignees changes every assignee
+the issue lacks is validated again, so a change of the spec is never considered equal
+*/
+func isAssigneesEqual(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	return isAssigneesValidated(ghissue) && equalNameSets(issue.Assignees, desiredAssignees(ghissue))
+}
+
+func isAssigneesValidated(ghissue *g.GithubIssue) bool {
+	return equalNameSets(ghissue.Status.ValidatedAssignees, ghissue.Spec.Assignees)
+}
+
+/*
+spec.assignees without the ones the tracker rejected when they were validated
+*/
+func desiredAssignees(ghissue *g.GithubIssue) []string {
+	if !isAssigneesValidated(ghissue) {
+		return ghissue.Spec.Assignees
+	}
+	return missingNames(ghissue.Spec.Assignees, ghissue.Status.RejectedAssignees)
+}
+
+/*
+the names in a that are not in b (case insensitive)
+*/
+func missingNames(a, b []string) []string {
+	inB := caseInsensitiveSet(b)
+	var missing []string
+	for _, name := range a {
+		if !inB[strings.ToLower(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func setCondition(ghissue *g.GithubIssue, condType string, status metav1.ConditionStatus, reason, message string) {
+	setStatusCondition(&ghissue.Status.Conditions, ghissue.Generation, condType, status, reason, message)
+}
+
+func setStatusCondition(conditions *[]metav1.Condition, generation int64, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+/*
+meta.RemoveStatusCondition of apimachinery v0.19 panics on an empty list, so conditions that are not there are skipped
+*/
+func removeCondition(ghissue *g.GithubIssue, condType string) {
+	if meta.FindStatusCondition(ghissue.Status.Conditions, condType) == nil {
+		return
+	}
+	meta.RemoveStatusCondition(&ghissue.Status.Conditions, condType)
+}
+
+// specError is a failure only an edit of the spec can fix, retrying does not help. Reconcilers record it with its
+// reason in the Ready condition and do not requeue the object
+type specError struct {
+	reason  string
+	message string
+}
+
+func (e *specError) Error() string {
+	return e.message
+}
+
+/*
+the reason to record a failure to set up the client for an object with, and the error to return from Reconcile:
+nil for a specError, as the object is reconciled again once its spec changes
+*/
+func credentialsFailure(err error) (reason string, retry error) {
+	var specErr *specError
+	if errors.As(err, &specErr) {
+		return specErr.reason, nil
+	}
+	return g.ReasonCredentialsError, err
+}
+
+func isSpecError(err error) bool {
+	var specErr *specError
+	return errors.As(err, &specErr)
+}
+
+/*
+records a failed step (reason) of the reconcile of obj in the Ready condition among conditions (obj's) and as an
+event, without touching observedGeneration (so an edit is retried). Returns what Reconcile returns: a requeue once
+the quota resets for rate limit errors, no requeue for a specError (only an edit of the spec fixes it), err otherwise
+so the request is requeued
+*/
+func readyFailed(ctx context.Context, k8sClient client.Client, recorder record.EventRecorder, logger logr.Logger, obj client.Object, conditions *[]metav1.Condition, reason string, err error) (ctrl.Result, error) {
+	setStatusCondition(conditions, obj.GetGeneration(), g.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+	recordFailureEvent(recorder, obj, reason, err)
+	if statusErr := k8sClient.Status().Update(ctx, obj); statusErr != nil {
+		logger.Error(statusErr, "Status().Update() failed ")
+	}
+	if after, ok := rateLimitRequeue(err); ok {
+		return ctrl.Result{RequeueAfter: after}, nil // retrying earlier would only burn the quota
+	}
+	if isSpecError(err) {
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+/*
+record that the spec was applied to the github issue
+*/
+func setSyncedConditions(ghissue *g.GithubIssue, issue *tracker.Issue) {
+	message := fmt.Sprintf("Issue #%d is in sync with the spec", issue.Number)
+	if len(ghissue.Status.RejectedAssignees) > 0 {
+		message = fmt.Sprintf("%s, except for the rejected assignees %v", message, ghissue.Status.RejectedAssignees)
+	}
+	setCondition(ghissue, g.ConditionSynced, metav1.ConditionTrue, g.ReasonIssueSynced, message)
+	setCondition(ghissue, g.ConditionReady, metav1.ConditionTrue, g.ReasonIssueSynced, message)
+}
+
+/*
+record that the reconcile failed at the step described by reason
+*/
+func setFailedConditions(ghissue *g.GithubIssue, reason string, err error) {
+	message := "unexpected response from Github"
+	if err != nil {
+		message = err.Error()
+	}
+	setCondition(ghissue, g.ConditionSynced, metav1.ConditionFalse, reason, message)
+	setCondition(ghissue, g.ConditionReady, metav1.ConditionFalse, reason, message)
+	setGithubReachableCondition(ghissue, err)
+}
+
+/*
+github is unreachable if the request did not get an answer at all, or github answered with a server error.
+Any other error (e.g. 404, 422) is an answer, so github is reachable
+*/
+func setGithubReachableCondition(ghissue *g.GithubIssue, err error) {
+	if err == nil {
+		setCondition(ghissue, g.ConditionGitHubReachable, metav1.ConditionTrue, g.ReasonConnected, "Github API answered")
+		return
+	}
+	var statusErr *tracker.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		if statusErr.StatusCode >= 500 {
+			setCondition(ghissue, g.ConditionGitHubReachable, metav1.ConditionFalse, g.ReasonGitHubServerError, err.Error())
+			return
+		}
+	case isRateLimitError(err):
+	default:
+		setCondition(ghissue, g.ConditionGitHubReachable, metav1.ConditionFalse, g.ReasonConnectionFailed, err.Error())
+		return
+	}
+	setCondition(ghissue, g.ConditionGitHubReachable, metav1.ConditionTrue, g.ReasonConnected, "Github API answered")
+}
+
+/*
+the tracker answered 404 or 410 for an issue: it was deleted, or transferred to another repository
+*/
+func isIssueGone(err error) bool {
+	var statusErr *tracker.StatusError
+	return errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone)
+}
+
+func setListTruncatedCondition(ghissue *g.GithubIssue, truncated bool, opts tracker.ListOptions) {
+	if truncated {
+		setCondition(ghissue, g.ConditionIssueListTruncated, metav1.ConditionTrue, g.ReasonMaxIssuesReached,
+			fmt.Sprintf("Repository has more than %d issues, only the first %d were searched; raise --github-list-max-issues to search all of them", opts.MaxIssues, opts.MaxIssues))
+		return
+	}
+	setCondition(ghissue, g.ConditionIssueListTruncated, metav1.ConditionFalse, g.ReasonAllIssuesListed, "All issues of the repository were searched")
+}
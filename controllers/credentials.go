@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// defaultCredentialsKey is the key of the token in the Secret referenced by spec.credentialsRef if no key is given
+	defaultCredentialsKey = "token"
+	// keys of a credentials Secret of a Github App (instead of a token)
+	appIDKey          = "appID"
+	installationIDKey = "installationID" // optional, looked up per repository owner if missing
+	privateKeyKey     = "privateKey"
+	// key of the user a token belongs to, for trackers authenticating with both (Jira Cloud: the account's email)
+	usernameKey = "username"
+	// key of the CA bundle trusted when talking to a Github Enterprise Server (see spec.baseURL)
+	caBundleKey = "ca.crt"
+	// credentialsRefNameField indexes GithubIssue objects by the name of the Secret they take their token from
+	credentialsRefNameField = ".spec.credentialsRef.name"
+)
+
+// GithubClients caches one github client per credential (and, for Github Apps, per repository owner, as every owner
+// has its own installation), so the connections and tokens are reused across reconciles. A client is rebuilt when the
+// Secret behind its credential changes, so a rotated token or key takes effect on the next reconcile.
+type GithubClients struct {
+	// DefaultSecret holds the operator-wide credentials. If empty, the TOKEN environment variable is used
+	DefaultSecret types.NamespacedName
+	// DefaultEndpoint is used by objects without spec.baseURL
+	DefaultEndpoint GithubEndpoint
+
+	limiter *rateLimiter
+
+	mu      sync.Mutex
+	clients map[string]*cachedClient // key is credentialsScope(), plus the owner for Github Apps
+}
+
+type cachedClient struct {
+	fingerprint string // of the credentials the client was built from
+	client      *github.Client
+	httpClient  *http.Client // of a tracker other than github
+}
+
+// githubCredentials is either a token or a Github App
+type githubCredentials struct {
+	token          string
+	username       string // optional, next to token
+	appID          int64
+	installationID int64
+	privateKey     []byte
+	caBundle       []byte
+}
+
+// NewGithubClients returns an empty cache of clients, to be shared by all reconcilers
+func NewGithubClients(defaultSecret types.NamespacedName, defaultEndpoint GithubEndpoint, rateLimitThreshold int) *GithubClients {
+	return &GithubClients{
+		DefaultSecret:   defaultSecret,
+		DefaultEndpoint: defaultEndpoint,
+		limiter:         newRateLimiter(rateLimitThreshold),
+		clients:         map[string]*cachedClient{},
+	}
+}
+
+// githubTarget is what an object needs to talk to github: the repository, and the endpoint and credentials to reach it
+// with. Every kind managing something on github fills it from its spec
+type githubTarget struct {
+	namespace      string
+	repo           string
+	credentialsRef *g.CredentialsRef
+	baseURL        string
+	uploadURL      string
+	provider       g.Provider // empty for github
+}
+
+func issueTarget(ghissue *g.GithubIssue) githubTarget {
+	target := githubTarget{
+		namespace:      ghissue.Namespace,
+		repo:           ghissue.Spec.Repo,
+		credentialsRef: ghissue.Spec.CredentialsRef,
+		baseURL:        ghissue.Spec.BaseURL,
+		uploadURL:      ghissue.Spec.UploadURL,
+	}
+	if ghissue.Spec.Provider != g.ProviderGitHub {
+		target.provider = ghissue.Spec.Provider
+	}
+	return target
+}
+
+/*
+returns the github client for target: authenticated with the credentials in spec.credentialsRef (a Secret in the
+object's namespace) if set, otherwise with the operator-wide default credentials (only for the operator-wide endpoint)
+*/
+func (c *GithubClients) clientFor(ctx context.Context, k8sClient client.Client, target githubTarget) (*github.Client, error) {
+	owner, _, err := splitOwnerRepo(target.repo)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := c.credentialsFor(ctx, k8sClient, target)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := endpointFor(target, c.DefaultEndpoint, creds.caBundle)
+	key := credentialsScope(target)
+	if creds.appID != 0 {
+		key += " " + owner
+	}
+	fingerprint := creds.fingerprint(endpoint)
+
+	c.mu.Lock()
+	cached, ok := c.clients[key]
+	c.mu.Unlock()
+	if ok && cached.fingerprint == fingerprint {
+		return cached.client, nil
+	}
+	/* built without holding c.mu: a Github App client looks up its installation on github, which must not hold up
+	the reconciles of all other objects */
+	var githubClient *github.Client
+	if creds.appID != 0 {
+		githubClient, err = getGithubAppClient(ctx, endpoint, creds.appID, creds.installationID, creds.privateKey, owner, c.limiter, key)
+	} else {
+		githubClient, err = getGithubClient(creds.token, endpoint, c.limiter, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.clients[key]; ok && cached.fingerprint == fingerprint {
+		return cached.client, nil // built by a concurrent reconcile in the meantime, keep the one already in use
+	}
+	c.clients[key] = &cachedClient{fingerprint: fingerprint, client: githubClient}
+	return githubClient, nil
+}
+
+/*
+returns the http client, API URL and credentials for target on a tracker other than github, which only take tokens.
+spec.credentialsRef is required, as the operator-wide credentials are github's. defaultBaseURL is used without
+spec.baseURL, spec.baseURL is required if it is empty
+*/
+func (c *GithubClients) tokenClientFor(ctx context.Context, k8sClient client.Client, target githubTarget, defaultBaseURL string) (httpClient *http.Client, baseURL string, creds githubCredentials, err error) {
+	if target.credentialsRef == nil {
+		return nil, "", githubCredentials{}, &specError{reason: g.ReasonCredentialsRefRequired, message: fmt.Sprintf("spec.credentialsRef is required with provider %s", target.provider)}
+	}
+	if target.baseURL == "" && defaultBaseURL == "" {
+		return nil, "", githubCredentials{}, invalidSpec("spec.baseURL is required with provider %s", target.provider)
+	}
+	creds, err = c.credentialsFor(ctx, k8sClient, target)
+	if err != nil {
+		return nil, "", githubCredentials{}, err
+	}
+	if creds.appID != 0 {
+		/* a specError as well: the Secret watch reconciles the object again once the Secret holds a token */
+		return nil, "", githubCredentials{}, invalidSpec("credentials Secret %s holds a Github App, provider %s needs a token", target.credentialsRef.Name, target.provider)
+	}
+	endpoint := GithubEndpoint{BaseURL: target.baseURL, CABundle: creds.caBundle}
+	if endpoint.BaseURL == "" {
+		endpoint.BaseURL = defaultBaseURL
+	}
+	key := credentialsScope(target)
+	fingerprint := creds.fingerprint(endpoint)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.clients[key]
+	if ok && cached.fingerprint == fingerprint {
+		return cached.httpClient, endpoint.BaseURL, creds, nil
+	}
+	httpClient, err = endpoint.httpClient()
+	if err != nil {
+		return nil, "", githubCredentials{}, err
+	}
+	httpClient = c.limiter.wrap(httpClient, key)
+	c.clients[key] = &cachedClient{fingerprint: fingerprint, httpClient: httpClient}
+	return httpClient, endpoint.BaseURL, creds, nil
+}
+
+/*
+whether an object being deleted has to give up its github side because setting up its client failed with err: its
+credentials Secret is gone (it is usually deleted along with the namespace) or its spec is invalid (which is never
+fixed on an object being deleted). Keeping the finalizer would only block the deletion
+*/
+func isUnrecoverableOnDeletion(err error, target githubTarget) bool {
+	return (apierrors.IsNotFound(err) && target.credentialsRef != nil) || isSpecError(err)
+}
+
+func (c *GithubClients) credentialsFor(ctx context.Context, k8sClient client.Client, target githubTarget) (githubCredentials, error) {
+	if ref := target.credentialsRef; ref != nil {
+		return readCredentialsSecret(ctx, k8sClient, types.NamespacedName{Namespace: target.namespace, Name: ref.Name}, credentialsKey(ref))
+	}
+	if !isDefaultEndpoint(target, c.DefaultEndpoint) {
+		return githubCredentials{}, &specError{reason: g.ReasonCredentialsRefRequired,
+			message: fmt.Sprintf("spec.credentialsRef is required with spec.baseURL %s, the operator-wide credentials are only sent to the operator-wide endpoint", target.baseURL)}
+	}
+	if c.DefaultSecret.Name != "" {
+		return readCredentialsSecret(ctx, k8sClient, c.DefaultSecret, defaultCredentialsKey)
+	}
+	return githubCredentials{token: os.Getenv("TOKEN")}, nil
+}
+
+/*
+a Secret with a privateKey holds a Github App, any other Secret holds a token under tokenKey (and optionally the user
+it belongs to under username)
+*/
+func readCredentialsSecret(ctx context.Context, k8sClient client.Client, name types.NamespacedName, tokenKey string) (githubCredentials, error) {
+	secret := corev1.Secret{}
+	err := k8sClient.Get(ctx, name, &secret)
+	if err != nil {
+		return githubCredentials{}, fmt.Errorf("reading credentials Secret %s: %w", name, err)
+	}
+	caBundle := secret.Data[caBundleKey]
+	if privateKey, ok := secret.Data[privateKeyKey]; ok {
+		creds := githubCredentials{privateKey: privateKey, caBundle: caBundle}
+		creds.appID, err = strconv.ParseInt(strings.TrimSpace(string(secret.Data[appIDKey])), 10, 64)
+		if err != nil {
+			return githubCredentials{}, fmt.Errorf("credentials Secret %s has no valid %q: %w", name, appIDKey, err)
+		}
+		if installationID, ok := secret.Data[installationIDKey]; ok {
+			creds.installationID, err = strconv.ParseInt(strings.TrimSpace(string(installationID)), 10, 64)
+			if err != nil {
+				return githubCredentials{}, fmt.Errorf("credentials Secret %s has no valid %q: %w", name, installationIDKey, err)
+			}
+		}
+		return creds, nil
+	}
+	token, ok := secret.Data[tokenKey]
+	if !ok || len(token) == 0 {
+		return githubCredentials{}, fmt.Errorf("credentials Secret %s has no key %q", name, tokenKey)
+	}
+	return githubCredentials{token: string(token), username: strings.TrimSpace(string(secret.Data[usernameKey])), caBundle: caBundle}, nil
+}
+
+func (creds githubCredentials) fingerprint(endpoint GithubEndpoint) string {
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s\x00%s\x00%d\x00%d\x00%s\x00%s\x00", creds.token, creds.username, creds.appID, creds.installationID, endpoint.BaseURL, endpoint.UploadURL)
+	sum.Write(creds.privateKey)
+	sum.Write(endpoint.CABundle)
+	return string(sum.Sum(nil))
+}
+
+func credentialsKey(ref *g.CredentialsRef) string {
+	if ref.Key == "" {
+		return defaultCredentialsKey
+	}
+	return ref.Key
+}
+
+/*
+identifies the Github instance and the credential target talks to it with: spec.baseURL (empty for the operator-wide
+default endpoint), plus namespace/name/key of spec.credentialsRef (empty for the operator-wide default credentials),
+prefixed by spec.provider for trackers other than github.
+Everything cached per credential (clients, issue lists) is keyed by it, so teams never see each other's cached data
+*/
+func credentialsScope(target githubTarget) string {
+	scope := target.baseURL
+	if ref := target.credentialsRef; ref != nil {
+		scope += " " + target.namespace + "/" + ref.Name + "/" + credentialsKey(ref)
+	}
+	if target.provider != "" {
+		scope = string(target.provider) + " " + scope
+	}
+	return scope
+}
+
+/*
+maps a Secret to the objects (listed with a list made by newList) using it as credentials, so they are reconciled
+when the token changes
+*/
+func objectsForSecret(k8sClient client.Client, logger logr.Logger, newList func() client.ObjectList) handler.MapFunc {
+	return func(secret client.Object) []reconcile.Request {
+		list := newList()
+		err := k8sClient.List(context.Background(), list, client.InNamespace(secret.GetNamespace()), client.MatchingFields{credentialsRefNameField: secret.GetName()})
+		if err != nil {
+			logger.Error(err, "Listing the objects using a Secret failed", "secret", secret.GetNamespace()+"/"+secret.GetName())
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			logger.Error(err, "Listing the objects using a Secret failed", "secret", secret.GetNamespace()+"/"+secret.GetName())
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			obj, err := meta.Accessor(item)
+			if err != nil {
+				continue
+			}
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}})
+		}
+		return requests
+	}
+}
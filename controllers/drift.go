@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// maxDriftValueLength bounds the values recorded in status.drift, so a long description does not bloat the object
+const maxDriftValueLength = 80
+
+var driftedIssues = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "githubissue_drifted_issues",
+	Help: "Number of GithubIssue objects with driftPolicy Report whose issue differs from the spec",
+}, []string{"repo"})
+
+func init() {
+	metrics.Registry.MustRegister(driftedIssues)
+}
+
+/*
+the fields of the issue that differ from the spec. The ownership marker is not part of the description, labels
+are compared according to spec.labelPolicy, and assignees the tracker rejected are not expected on the issue
+*/
+func detectDrift(issue *tracker.Issue, ghissue *g.GithubIssue) []g.FieldDrift {
+	var drift []g.FieldDrift
+	if !isTitleEqual(issue, ghissue) {
+		drift = append(drift, g.FieldDrift{Field: "title", Spec: shorten(ghissue.Spec.Title), GitHub: shorten(issue.Title)})
+	}
+	if body := bodyWithoutMarker(issue.Body); body != ghissue.Spec.Desc {
+		specLine, githubLine := firstDifferentLine(ghissue.Spec.Desc, body)
+		drift = append(drift, g.FieldDrift{Field: "description", Spec: shorten(specLine), GitHub: shorten(githubLine)})
+	}
+	if labels := desiredLabels(issue, ghissue); !isLabelsEqual(issue, labels) {
+		drift = append(drift, g.FieldDrift{Field: "labels", Spec: shorten(joinSorted(labels)), GitHub: shorten(joinSorted(issue.Labels))})
+	}
+	if desired := desiredState(ghissue); issue.State != desired {
+		drift = append(drift, g.FieldDrift{Field: "state", Spec: desired, GitHub: issue.State})
+	}
+	if assignees := desiredAssignees(ghissue); !equalNameSets(issue.Assignees, assignees) {
+		drift = append(drift, g.FieldDrift{Field: "assignees", Spec: shorten(joinSorted(assignees)), GitHub: shorten(joinSorted(issue.Assignees))})
+	}
+	return drift
+}
+
+/*
+records the drift in status.drift and the Drifted condition
+*/
+func setDrift(ghissue *g.GithubIssue, drift []g.FieldDrift) {
+	ghissue.Status.Drift = drift
+	if len(drift) == 0 {
+		setCondition(ghissue, g.ConditionDrifted, metav1.ConditionFalse, g.ReasonNoDrift, "Issue matches the spec")
+		return
+	}
+	fields := make([]string, 0, len(drift))
+	for _, d := range drift {
+		fields = append(fields, d.Field)
+	}
+	setCondition(ghissue, g.ConditionDrifted, metav1.ConditionTrue, g.ReasonDriftDetected,
+		fmt.Sprintf("Issue differs from the spec in %s, see status.drift", strings.Join(fields, ", ")))
+}
+
+func clearDrift(ghissue *g.GithubIssue) {
+	ghissue.Status.Drift = nil
+	removeCondition(ghissue, g.ConditionDrifted)
+}
+
+/*
+the first line that differs between the two texts
+*/
+func firstDifferentLine(a, b string) (string, string) {
+	linesA, linesB := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; i < len(linesA) || i < len(linesB); i++ {
+		var lineA, lineB string
+		if i < len(linesA) {
+			lineA = linesA[i]
+		}
+		if i < len(linesB) {
+			lineB = linesB[i]
+		}
+		if lineA != lineB {
+			return lineA, lineB
+		}
+	}
+	return "", ""
+}
+
+func joinSorted(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+func shorten(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxDriftValueLength {
+		return s
+	}
+	return string(runes[:maxDriftValueLength-3]) + "..."
+}
+
+// driftTracker remembers which GithubIssue objects are drifted, to keep the githubissue_drifted_issues gauge
+type driftTracker struct {
+	mu      sync.Mutex
+	drifted map[types.NamespacedName]string // object -> repo
+}
+
+func newDriftTracker() *driftTracker {
+	return &driftTracker{drifted: map[types.NamespacedName]string{}}
+}
+
+func (t *driftTracker) set(name types.NamespacedName, repo string, drifted bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if previous, ok := t.drifted[name]; ok {
+		delete(t.drifted, name)
+		t.updateGauge(previous)
+	}
+	if drifted {
+		t.drifted[name] = repo
+		t.updateGauge(repo)
+	}
+}
+
+func (t *driftTracker) forget(name types.NamespacedName) {
+	t.set(name, "", false)
+}
+
+func (t *driftTracker) updateGauge(repo string) {
+	count := 0
+	for _, r := range t.drifted {
+		if r == repo {
+			count++
+		}
+	}
+	driftedIssues.WithLabelValues(repo).Set(float64(count))
+}
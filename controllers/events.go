@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// reasons of the events recorded by the controllers, one per github side effect
+const (
+	eventIssueCreated          = "IssueCreated"
+	eventIssueAdopted          = "IssueAdopted"
+	eventIssueNotOwned         = "IssueNotOwned"
+	eventSpecUpdatedFromGithub = "SpecUpdatedFromGitHub"
+	eventSyncConflict          = "SyncConflict"
+	eventDescriptionUpdated    = "DescriptionUpdated"
+	eventLabelsUpdated         = "LabelsUpdated"
+	eventAssigneesUpdated      = "AssigneesUpdated"
+	eventAssigneesRejected     = "AssigneesRejected"
+	eventIssueClosed           = "IssueClosed"
+	eventIssueReopened         = "IssueReopened"
+	eventIssueLocked           = "IssueLocked"
+	eventIssueNotLocked        = "IssueNotLocked"
+	eventInvalidSpec           = "InvalidSpec"
+	eventIssueRetained         = "IssueRetained"
+	eventIssueAbandoned        = "IssueAbandoned"
+	eventCommentPosted         = "CommentPosted"
+	eventGitHubAPIError        = "GitHubAPIError"
+	eventRateLimited           = "RateLimited"
+	eventCredentialsError      = "CredentialsError"
+	eventCommentUpdated        = "CommentUpdated"
+	eventCommentDeleted        = "CommentDeleted"
+	eventCommentMinimized      = "CommentMinimized"
+	eventCommentAbandoned      = "CommentAbandoned"
+	eventLabelCreated          = "LabelCreated"
+	eventLabelAdopted          = "LabelAdopted"
+	eventLabelUpdated          = "LabelUpdated"
+	eventLabelDeleted          = "LabelDeleted"
+	eventLabelReleased         = "LabelReleased"
+	eventLabelAbandoned        = "LabelAbandoned"
+	eventMilestoneAssigned     = "MilestoneAssigned"
+	eventMilestoneCreated      = "MilestoneCreated"
+	eventMilestoneAdopted      = "MilestoneAdopted"
+	eventMilestoneUpdated      = "MilestoneUpdated"
+	eventMilestoneDeleted      = "MilestoneDeleted"
+	eventMilestoneReleased     = "MilestoneReleased"
+	eventMilestoneAbandoned    = "MilestoneAbandoned"
+)
+
+/*
+the Warning event of a failed step (reason) of a reconcile
+*/
+func recordFailureEvent(recorder record.EventRecorder, obj runtime.Object, reason string, err error) {
+	if isRateLimitError(err) {
+		recorder.Eventf(obj, corev1.EventTypeWarning, eventRateLimited, "%s: %v", reason, err)
+	} else {
+		recorder.Eventf(obj, corev1.EventTypeWarning, eventGitHubAPIError, "%s: %v", reason, err)
+	}
+}
+
+func isRateLimitError(err error) bool {
+	_, ok := rateLimitRequeue(err)
+	return ok
+}
@@ -0,0 +1,968 @@
+package controllers
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeGithub is an in-memory Github serving the parts of the REST API the controllers use: the issues of a repository
+// (listed with pagination and ETags), their comments, labels, assignees and locks, the labels and milestones of the
+// repository, and the GraphQL mutation minimizing a comment. Every answer carries the X-RateLimit-* headers. Point a
+// GithubEndpoint at endpoint() to reconcile against it instead of github.com. Github Apps registered with installApp
+// look up their installations with their JWT, and mint installation tokens that are as good as token
+type fakeGithub struct {
+	server *httptest.Server
+	// token the requests have to be authenticated with
+	token string
+
+	mu    sync.Mutex
+	repos map[string]*fakeRepo // by owner/repo
+	// failures answer the next requests they match with an error instead of serving them
+	failures []*fakeFailure
+	// requests holds "METHOD path" of every request served, path relative to the API
+	requests []string
+	// rateLimitRemaining is reported (and decremented) by every answer, rateLimitReset is when the quota resets
+	rateLimitRemaining int
+	rateLimitReset     time.Time
+	// lastCommentID numbers the comments of all repositories, like github does
+	lastCommentID int64
+	// apps by app ID
+	apps               map[int64]*fakeApp
+	lastInstallationID int64
+}
+
+// fakeApp is a Github App, installed on organizations and users
+type fakeApp struct {
+	key               *rsa.PublicKey
+	orgInstallations  map[string]int64 // by organization
+	userInstallations map[string]int64 // by user
+}
+
+type fakeRepo struct {
+	issues     []*fakeIssue           // issue number n at index n-1
+	labels     map[string]*fakeLabel  // by lower case name, label names are case insensitive
+	milestones []*fakeMilestone       // milestone number n at index n-1
+	comments   map[int64]*fakeComment // by ID
+	assignable map[string]bool        // logins that can be assigned to issues
+}
+
+// fakeIssue is an issue as the Github API returns it
+type fakeIssue struct {
+	Number      int             `json:"number"`
+	NodeID      string          `json:"node_id"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	State       string          `json:"state"`
+	StateReason string          `json:"state_reason,omitempty"`
+	Labels      []fakeLabel     `json:"labels"`
+	Assignees   []fakeUser      `json:"assignees"`
+	Milestone   *fakeMilestone  `json:"milestone"`
+	Locked      bool            `json:"locked"`
+	HTMLURL     string          `json:"html_url"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	PullRequest json.RawMessage `json:"pull_request,omitempty"`
+	// Comments are the bodies of the comments on the issue, filled in by issue()
+	Comments []string `json:"-"`
+	// Deleted issues are answered with 404 and left out of listings
+	Deleted bool `json:"-"`
+}
+
+type fakeLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color,omitempty"`
+	Description string `json:"description,omitempty"`
+	NodeID      string `json:"node_id,omitempty"`
+}
+
+type fakeUser struct {
+	Login string `json:"login"`
+}
+
+// fakeMilestone is a milestone as the Github API returns it. The milestone of an issue only has its number
+type fakeMilestone struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title,omitempty"`
+	Description string     `json:"description,omitempty"`
+	State       string     `json:"state,omitempty"`
+	DueOn       *time.Time `json:"due_on,omitempty"`
+	NodeID      string     `json:"node_id,omitempty"`
+	HTMLURL     string     `json:"html_url,omitempty"`
+	// Deleted milestones are answered with 404 and left out of listings
+	Deleted bool `json:"-"`
+}
+
+type fakeComment struct {
+	ID      int64  `json:"id"`
+	NodeID  string `json:"node_id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	// Issue is the number of the issue the comment is on
+	Issue     int  `json:"-"`
+	Minimized bool `json:"-"`
+	Deleted   bool `json:"-"`
+}
+
+// fakeFailure answers requests with method to a path ending with pathSuffix with status, times times
+type fakeFailure struct {
+	method     string
+	pathSuffix string
+	status     int
+	times      int
+}
+
+func newFakeGithub(token string) *fakeGithub {
+	f := &fakeGithub{
+		token:              token,
+		repos:              map[string]*fakeRepo{},
+		apps:               map[int64]*fakeApp{},
+		rateLimitRemaining: 5000,
+		rateLimitReset:     time.Now().Add(time.Hour),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeGithub) close() {
+	f.server.Close()
+}
+
+/*
+the endpoint to configure the controller with
+*/
+func (f *fakeGithub) endpoint() GithubEndpoint {
+	return GithubEndpoint{BaseURL: f.server.URL + "/api/v3/"}
+}
+
+/*
+creates the repository (without issues) if it does not exist. Requests to other repositories are answered with 404
+*/
+func (f *fakeGithub) addRepo(repo string, assignable ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.repos[repo] == nil {
+		f.repos[repo] = &fakeRepo{labels: map[string]*fakeLabel{}, comments: map[int64]*fakeComment{}, assignable: map[string]bool{}}
+	}
+	for _, login := range assignable {
+		f.repos[repo].assignable[strings.ToLower(login)] = true
+	}
+}
+
+/*
+files an issue the way a human would, returns its number
+*/
+func (f *fakeGithub) addIssue(repo, title, body string) int {
+	f.addRepo(repo)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.createIssue(repo, title, body, nil, nil).Number
+}
+
+/*
+installs the Github App appID (registering it with its public key on first use) on owner, an organization or a user.
+Returns the installation ID
+*/
+func (f *fakeGithub) installApp(appID int64, key *rsa.PublicKey, owner string, organization bool) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	app := f.apps[appID]
+	if app == nil {
+		app = &fakeApp{key: key, orgInstallations: map[string]int64{}, userInstallations: map[string]int64{}}
+		f.apps[appID] = app
+	}
+	f.lastInstallationID++
+	if organization {
+		app.orgInstallations[owner] = f.lastInstallationID
+	} else {
+		app.userInstallations[owner] = f.lastInstallationID
+	}
+	return f.lastInstallationID
+}
+
+/*
+a copy of the issue, nil if it does not exist
+*/
+func (f *fakeGithub) issue(repo string, number int) *fakeIssue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.repos[repo]
+	if r == nil || number < 1 || number > len(r.issues) {
+		return nil
+	}
+	copied := *r.issues[number-1]
+	copied.Labels = append([]fakeLabel(nil), copied.Labels...)
+	copied.Assignees = append([]fakeUser(nil), copied.Assignees...)
+	copied.Comments = nil
+	for _, comment := range r.sortedComments() {
+		if comment.Issue == number && !comment.Deleted {
+			copied.Comments = append(copied.Comments, comment.Body)
+		}
+	}
+	return &copied
+}
+
+/*
+creates a label the way a human would, to be adopted
+*/
+func (f *fakeGithub) addLabel(repo, name, color, description string) {
+	f.addRepo(repo)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createLabel(f.repos[repo], name, color, description)
+}
+
+/*
+a copy of the label, nil if the repo has no label named name
+*/
+func (f *fakeGithub) label(repo, name string) *fakeLabel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.repos[repo]
+	if r == nil || r.labels[strings.ToLower(name)] == nil {
+		return nil
+	}
+	copied := *r.labels[strings.ToLower(name)]
+	return &copied
+}
+
+/*
+creates an open milestone the way a human would, returns its number
+*/
+func (f *fakeGithub) addMilestone(repo, title string) int {
+	f.addRepo(repo)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.createMilestone(repo, title).Number
+}
+
+/*
+a copy of the milestone, nil if it does not exist
+*/
+func (f *fakeGithub) milestone(repo string, number int) *fakeMilestone {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.repos[repo]
+	if r == nil || number < 1 || number > len(r.milestones) || r.milestones[number-1].Deleted {
+		return nil
+	}
+	copied := *r.milestones[number-1]
+	return &copied
+}
+
+/*
+a copy of the comment, nil if it does not exist. Deleted comments are returned with Deleted set
+*/
+func (f *fakeGithub) comment(repo string, id int64) *fakeComment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r := f.repos[repo]
+	if r == nil || r.comments[id] == nil {
+		return nil
+	}
+	copied := *r.comments[id]
+	return &copied
+}
+
+/*
+deletes the comment the way a human would on github
+*/
+func (f *fakeGithub) deleteComment(repo string, id int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.repos[repo].comments[id].Deleted = true
+}
+
+/*
+edits the issue the way a human would on github
+*/
+func (f *fakeGithub) editIssue(repo string, number int, edit func(issue *fakeIssue)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	issue := f.repos[repo].issues[number-1]
+	edit(issue)
+	issue.UpdatedAt = time.Now()
+}
+
+/*
+deletes the issue the way an admin would on github
+*/
+func (f *fakeGithub) deleteIssue(repo string, number int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.repos[repo].issues[number-1].Deleted = true
+}
+
+func (f *fakeGithub) issueCount(repo string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r := f.repos[repo]; r != nil {
+		return len(r.issues)
+	}
+	return 0
+}
+
+/*
+answers the next times requests with method to a path ending with pathSuffix (e.g. "/issues") with status
+*/
+func (f *fakeGithub) failNext(method, pathSuffix string, status, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, &fakeFailure{method: method, pathSuffix: pathSuffix, status: status, times: times})
+}
+
+func (f *fakeGithub) clearFailures() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = nil
+}
+
+/*
+reports remaining quota (resetting after resetIn) from the next answer on
+*/
+func (f *fakeGithub) setRateLimit(remaining int, resetIn time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rateLimitRemaining = remaining
+	f.rateLimitReset = time.Now().Add(resetIn)
+}
+
+/*
+how many requests with method to a path ending with pathSuffix were served
+*/
+func (f *fakeGithub) requestCount(method, pathSuffix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, method+" ") && strings.HasSuffix(request, pathSuffix) {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeGithub) serve(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.Trim(strings.TrimPrefix(req.URL.Path, "/api/v3"), "/")
+	f.requests = append(f.requests, req.Method+" "+path)
+
+	if time.Now().After(f.rateLimitReset) {
+		f.rateLimitRemaining = 5000
+		f.rateLimitReset = time.Now().Add(time.Hour)
+	}
+	if f.rateLimitRemaining > 0 {
+		f.rateLimitRemaining--
+	}
+	w.Header().Set("X-RateLimit-Limit", "5000")
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(f.rateLimitRemaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(f.rateLimitReset.Unix(), 10))
+	w.Header().Set("X-RateLimit-Resource", "core")
+
+	authorization := req.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") && strings.Count(authorization, ".") == 2 {
+		f.serveApp(w, req, path, strings.TrimPrefix(authorization, "Bearer "))
+		return
+	}
+	/* installation tokens are sent as "token <token>" */
+	if authorization != "Bearer "+f.token && authorization != "token "+f.token {
+		writeFakeError(w, http.StatusUnauthorized, "Bad credentials")
+		return
+	}
+	for _, failure := range f.failures {
+		if failure.times > 0 && failure.method == req.Method && strings.HasSuffix(path, failure.pathSuffix) {
+			failure.times--
+			writeFakeError(w, failure.status, "injected failure")
+			return
+		}
+	}
+
+	/* label names may contain escaped slashes, so the segments are split before unescaping them */
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.EscapedPath(), "/api/v3"), "/"), "/")
+	for i, segment := range segments {
+		segments[i], _ = url.PathUnescape(segment)
+	}
+	if path == "api/graphql" && req.Method == "POST" {
+		f.serveGraphQL(w, req)
+		return
+	}
+	if path == "rate_limit" {
+		writeFakeJSON(w, http.StatusOK, map[string]interface{}{"resources": map[string]interface{}{"core": map[string]interface{}{
+			"limit": 5000, "remaining": f.rateLimitRemaining, "reset": f.rateLimitReset.Unix(),
+		}}})
+		return
+	}
+	if len(segments) < 4 || segments[0] != "repos" {
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repo := segments[1] + "/" + segments[2]
+	r := f.repos[repo]
+	if r == nil {
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	switch {
+	case len(segments) == 4 && segments[3] == "issues":
+		f.serveIssues(w, req, repo, r)
+	case segments[3] == "labels":
+		f.serveLabels(w, req, r, segments[4:])
+	case segments[3] == "milestones":
+		f.serveMilestones(w, req, repo, r, segments[4:])
+	case len(segments) == 6 && segments[3] == "issues" && segments[4] == "comments":
+		id, _ := strconv.ParseInt(segments[5], 10, 64)
+		comment := r.comments[id]
+		if comment == nil || comment.Deleted {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		f.serveComment(w, req, comment)
+	case len(segments) == 5 && segments[3] == "assignees" && req.Method == "GET":
+		if r.assignable[strings.ToLower(segments[4])] {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+		}
+	case len(segments) >= 5 && segments[3] == "issues":
+		number, err := strconv.Atoi(segments[4])
+		if err != nil || number < 1 || number > len(r.issues) || r.issues[number-1].Deleted {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		f.serveIssue(w, req, r, r.issues[number-1], segments[5:])
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+/*
+GET lists the issues newest first, or most recently updated first with sort=updated (state defaults to open, since
+and per_page/page are supported, the Link header points to the next page), POST creates an issue
+*/
+func (f *fakeGithub) serveIssues(w http.ResponseWriter, req *http.Request, repo string, r *fakeRepo) {
+	switch req.Method {
+	case "GET":
+		query := req.URL.Query()
+		state := query.Get("state")
+		if state == "" {
+			state = "open"
+		}
+		var since time.Time
+		if s := query.Get("since"); s != "" {
+			since, _ = time.Parse(time.RFC3339, s)
+		}
+		matching := []*fakeIssue{}
+		for i := len(r.issues) - 1; i >= 0; i-- {
+			issue := r.issues[i]
+			if (state == "all" || issue.State == state) && !issue.UpdatedAt.Before(since) && !issue.Deleted {
+				matching = append(matching, issue)
+			}
+		}
+		if query.Get("sort") == "updated" {
+			sort.SliceStable(matching, func(i, j int) bool { return matching[i].UpdatedAt.After(matching[j].UpdatedAt) })
+		}
+		perPage, _ := strconv.Atoi(query.Get("per_page"))
+		if perPage <= 0 {
+			perPage = 30
+		}
+		page, _ := strconv.Atoi(query.Get("page"))
+		if page <= 0 {
+			page = 1
+		}
+		start, end := (page-1)*perPage, page*perPage
+		if start > len(matching) {
+			start = len(matching)
+		}
+		if end >= len(matching) {
+			end = len(matching)
+		} else {
+			query.Set("page", strconv.Itoa(page+1))
+			next := *req.URL
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next"`, f.server.URL, next.RequestURI()))
+		}
+		body, _ := json.Marshal(matching[start:end])
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		w.Header().Set("ETag", etag)
+		if req.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	case "POST":
+		var create struct {
+			Title     string   `json:"title"`
+			Body      string   `json:"body"`
+			Labels    []string `json:"labels"`
+			Assignees []string `json:"assignees"`
+		}
+		if json.NewDecoder(req.Body).Decode(&create) != nil || create.Title == "" {
+			writeFakeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		for _, login := range create.Assignees {
+			if !r.assignable[strings.ToLower(login)] {
+				writeFakeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+				return
+			}
+		}
+		writeFakeJSON(w, http.StatusCreated, f.createIssue(repo, create.Title, create.Body, create.Labels, create.Assignees))
+	default:
+		writeFakeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+/*
+the issue itself (GET, PATCH) and its comments, labels, assignees and lock
+*/
+func (f *fakeGithub) serveIssue(w http.ResponseWriter, req *http.Request, r *fakeRepo, issue *fakeIssue, rest []string) {
+	resource := strings.Join(rest, "/")
+	switch {
+	case resource == "" && req.Method == "GET":
+		writeFakeJSON(w, http.StatusOK, issue)
+	case resource == "" && req.Method == "PATCH":
+		var edit struct {
+			Title       *string         `json:"title"`
+			Body        *string         `json:"body"`
+			State       string          `json:"state"`
+			StateReason string          `json:"state_reason"`
+			Milestone   json.RawMessage `json:"milestone"`
+		}
+		if json.NewDecoder(req.Body).Decode(&edit) != nil {
+			writeFakeError(w, http.StatusBadRequest, "Problems parsing JSON")
+			return
+		}
+		if edit.Title != nil {
+			issue.Title = *edit.Title
+		}
+		if edit.Body != nil {
+			issue.Body = *edit.Body
+		}
+		if edit.State != "" {
+			issue.State = edit.State
+			issue.StateReason = edit.StateReason
+		}
+		if len(edit.Milestone) > 0 {
+			var number int
+			if json.Unmarshal(edit.Milestone, &number) == nil && number != 0 {
+				issue.Milestone = &fakeMilestone{Number: number}
+			} else {
+				issue.Milestone = nil
+			}
+		}
+		issue.UpdatedAt = time.Now()
+		writeFakeJSON(w, http.StatusOK, issue)
+	case resource == "comments" && req.Method == "POST":
+		var comment struct {
+			Body string `json:"body"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&comment)
+		f.lastCommentID++
+		posted := &fakeComment{ID: f.lastCommentID, Body: comment.Body, Issue: issue.Number}
+		posted.NodeID = fmt.Sprintf("IC_%d", posted.ID)
+		posted.HTMLURL = fmt.Sprintf("%s#issuecomment-%d", issue.HTMLURL, posted.ID)
+		r.comments[posted.ID] = posted
+		issue.UpdatedAt = time.Now()
+		writeFakeJSON(w, http.StatusCreated, posted)
+	case resource == "labels" && req.Method == "PUT":
+		var labels []string
+		_ = json.NewDecoder(req.Body).Decode(&labels)
+		issue.Labels = []fakeLabel{}
+		for _, name := range labels {
+			f.labelNamed(r, name)
+			issue.Labels = append(issue.Labels, fakeLabel{Name: name})
+		}
+		issue.UpdatedAt = time.Now()
+		writeFakeJSON(w, http.StatusOK, issue.Labels)
+	case resource == "assignees" && (req.Method == "POST" || req.Method == "DELETE"):
+		var change struct {
+			Assignees []string `json:"assignees"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&change)
+		for _, login := range change.Assignees {
+			issue.Assignees = removeFakeUser(issue.Assignees, login)
+			if req.Method == "POST" && r.assignable[strings.ToLower(login)] {
+				issue.Assignees = append(issue.Assignees, fakeUser{Login: login})
+			}
+		}
+		issue.UpdatedAt = time.Now()
+		writeFakeJSON(w, http.StatusCreated, issue)
+	case resource == "lock" && (req.Method == "PUT" || req.Method == "DELETE"):
+		issue.Locked = req.Method == "PUT"
+		issue.UpdatedAt = time.Now()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+/*
+GET lists the labels, POST creates one, GET, PATCH and DELETE of labels/<name> read, edit and delete a label
+*/
+func (f *fakeGithub) serveLabels(w http.ResponseWriter, req *http.Request, r *fakeRepo, rest []string) {
+	var body fakeLabel
+	switch {
+	case len(rest) == 0 && req.Method == "GET":
+		labels := []*fakeLabel{}
+		for _, label := range r.labels {
+			labels = append(labels, label)
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		writeFakeJSON(w, http.StatusOK, labels)
+	case len(rest) == 0 && req.Method == "POST":
+		if json.NewDecoder(req.Body).Decode(&body) != nil || body.Name == "" || r.labels[strings.ToLower(body.Name)] != nil {
+			writeFakeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		writeFakeJSON(w, http.StatusCreated, f.createLabel(r, body.Name, body.Color, body.Description))
+	case len(rest) == 1:
+		label := r.labels[strings.ToLower(rest[0])]
+		if label == nil {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		switch req.Method {
+		case "GET":
+			writeFakeJSON(w, http.StatusOK, label)
+		case "PATCH":
+			if json.NewDecoder(req.Body).Decode(&body) != nil {
+				writeFakeError(w, http.StatusBadRequest, "Problems parsing JSON")
+				return
+			}
+			if body.Name != "" && !strings.EqualFold(body.Name, label.Name) && r.labels[strings.ToLower(body.Name)] != nil {
+				writeFakeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+				return
+			}
+			delete(r.labels, strings.ToLower(label.Name))
+			if body.Name != "" {
+				label.Name = body.Name
+			}
+			label.Color, label.Description = body.Color, body.Description
+			r.labels[strings.ToLower(label.Name)] = label
+			writeFakeJSON(w, http.StatusOK, label)
+		case "DELETE":
+			delete(r.labels, strings.ToLower(label.Name))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeFakeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+/*
+GET lists the milestones (state defaults to open, all pages at once), POST creates one, GET, PATCH and DELETE of
+milestones/<number> read, edit and delete a milestone
+*/
+func (f *fakeGithub) serveMilestones(w http.ResponseWriter, req *http.Request, repo string, r *fakeRepo, rest []string) {
+	var body struct {
+		Title       *string    `json:"title"`
+		State       string     `json:"state"`
+		Description string     `json:"description"`
+		DueOn       *time.Time `json:"due_on"`
+	}
+	switch {
+	case len(rest) == 0 && req.Method == "GET":
+		state := req.URL.Query().Get("state")
+		if state == "" {
+			state = "open"
+		}
+		milestones := []*fakeMilestone{}
+		for _, milestone := range r.milestones {
+			if (state == "all" || milestone.State == state) && !milestone.Deleted {
+				milestones = append(milestones, milestone)
+			}
+		}
+		writeFakeJSON(w, http.StatusOK, milestones)
+	case len(rest) == 0 && req.Method == "POST":
+		if json.NewDecoder(req.Body).Decode(&body) != nil || body.Title == nil || *body.Title == "" {
+			writeFakeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+			return
+		}
+		milestone := f.createMilestone(repo, *body.Title)
+		if body.State != "" {
+			milestone.State = body.State
+		}
+		milestone.Description, milestone.DueOn = body.Description, body.DueOn
+		writeFakeJSON(w, http.StatusCreated, milestone)
+	case len(rest) == 1:
+		number, err := strconv.Atoi(rest[0])
+		if err != nil || number < 1 || number > len(r.milestones) || r.milestones[number-1].Deleted {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		milestone := r.milestones[number-1]
+		switch req.Method {
+		case "GET":
+			writeFakeJSON(w, http.StatusOK, milestone)
+		case "PATCH":
+			if json.NewDecoder(req.Body).Decode(&body) != nil {
+				writeFakeError(w, http.StatusBadRequest, "Problems parsing JSON")
+				return
+			}
+			if body.Title != nil {
+				milestone.Title = *body.Title
+			}
+			if body.State != "" {
+				milestone.State = body.State
+			}
+			milestone.Description, milestone.DueOn = body.Description, body.DueOn
+			writeFakeJSON(w, http.StatusOK, milestone)
+		case "DELETE":
+			milestone.Deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeFakeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+/*
+what a Github App may do with its JWT: look up its installation on an organization or a user, and mint a token for an
+installation. The token is the one the fake accepts for all other requests
+*/
+func (f *fakeGithub) serveApp(w http.ResponseWriter, req *http.Request, path, jwt string) {
+	app := f.verifyAppJWT(jwt)
+	if app == nil {
+		writeFakeError(w, http.StatusUnauthorized, "A JSON web token could not be decoded")
+		return
+	}
+	segments := strings.Split(path, "/")
+	switch {
+	case len(segments) == 3 && segments[2] == "installation" && req.Method == "GET" && (segments[0] == "orgs" || segments[0] == "users"):
+		installations := app.userInstallations
+		if segments[0] == "orgs" {
+			installations = app.orgInstallations
+		}
+		id, ok := installations[segments[1]]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeFakeJSON(w, http.StatusOK, map[string]int64{"id": id})
+	case len(segments) == 4 && segments[0] == "app" && segments[1] == "installations" && segments[3] == "access_tokens" && req.Method == "POST":
+		id, _ := strconv.ParseInt(segments[2], 10, 64)
+		for _, installations := range []map[string]int64{app.orgInstallations, app.userInstallations} {
+			for _, installed := range installations {
+				if installed == id {
+					writeFakeJSON(w, http.StatusCreated, map[string]interface{}{"token": f.token, "expires_at": time.Now().Add(time.Hour)})
+					return
+				}
+			}
+		}
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	default:
+		writeFakeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+/*
+the app that signed jwt, nil if no registered app did or jwt is expired. Callers hold f.mu
+*/
+func (f *fakeGithub) verifyAppJWT(jwt string) *fakeApp {
+	parts := strings.Split(jwt, ".")
+	encodedClaims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		IssuedAt  int64 `json:"iat"`
+		ExpiresAt int64 `json:"exp"`
+		Issuer    int64 `json:"iss"`
+	}
+	if json.Unmarshal(encodedClaims, &claims) != nil || f.apps[claims.Issuer] == nil {
+		return nil
+	}
+	if now := time.Now().Unix(); now < claims.IssuedAt || now > claims.ExpiresAt {
+		return nil
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(f.apps[claims.Issuer].key, crypto.SHA256, digest[:], signature) != nil {
+		return nil
+	}
+	return f.apps[claims.Issuer]
+}
+
+/*
+PATCH edits the body of the comment, DELETE deletes it
+*/
+func (f *fakeGithub) serveComment(w http.ResponseWriter, req *http.Request, comment *fakeComment) {
+	switch req.Method {
+	case "PATCH":
+		var edit struct {
+			Body string `json:"body"`
+		}
+		if json.NewDecoder(req.Body).Decode(&edit) != nil {
+			writeFakeError(w, http.StatusBadRequest, "Problems parsing JSON")
+			return
+		}
+		comment.Body = edit.Body
+		writeFakeJSON(w, http.StatusOK, comment)
+	case "DELETE":
+		comment.Deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+/*
+the only GraphQL call the controllers make is minimizeComment, with the node ID of the comment in variables.id
+*/
+func (f *fakeGithub) serveGraphQL(w http.ResponseWriter, req *http.Request) {
+	var call struct {
+		Query     string `json:"query"`
+		Variables struct {
+			ID string `json:"id"`
+		} `json:"variables"`
+	}
+	if json.NewDecoder(req.Body).Decode(&call) != nil || !strings.Contains(call.Query, "minimizeComment") {
+		writeFakeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	for _, r := range f.repos {
+		for _, comment := range r.comments {
+			if comment.NodeID == call.Variables.ID && !comment.Deleted {
+				comment.Minimized = true
+				writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+					"minimizeComment": map[string]interface{}{"minimizedComment": map[string]bool{"isMinimized": true}},
+				}})
+				return
+			}
+		}
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{"data": nil, "errors": []map[string]string{
+		{"message": fmt.Sprintf("Could not resolve to a node with the global id of '%s'", call.Variables.ID)},
+	}})
+}
+
+/*
+callers hold f.mu
+*/
+func (f *fakeGithub) createLabel(r *fakeRepo, name, color, description string) *fakeLabel {
+	label := &fakeLabel{Name: name, Color: strings.ToLower(color), Description: description}
+	label.NodeID = fmt.Sprintf("LA_%s", strings.ToLower(name))
+	r.labels[strings.ToLower(name)] = label
+	return label
+}
+
+/*
+the label named name, created with github's default color if the repo has none. Callers hold f.mu
+*/
+func (f *fakeGithub) labelNamed(r *fakeRepo, name string) *fakeLabel {
+	if label := r.labels[strings.ToLower(name)]; label != nil {
+		return label
+	}
+	return f.createLabel(r, name, "ededed", "")
+}
+
+/*
+callers hold f.mu
+*/
+func (f *fakeGithub) createMilestone(repo, title string) *fakeMilestone {
+	r := f.repos[repo]
+	milestone := &fakeMilestone{Number: len(r.milestones) + 1, Title: title, State: "open"}
+	milestone.NodeID = fmt.Sprintf("MI_%s_%d", strings.Replace(repo, "/", "_", -1), milestone.Number)
+	milestone.HTMLURL = fmt.Sprintf("%s/%s/milestone/%d", f.server.URL, repo, milestone.Number)
+	r.milestones = append(r.milestones, milestone)
+	return milestone
+}
+
+/*
+the comments in the order they were posted. Callers hold f.mu
+*/
+func (r *fakeRepo) sortedComments() []*fakeComment {
+	comments := make([]*fakeComment, 0, len(r.comments))
+	for _, comment := range r.comments {
+		comments = append(comments, comment)
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments
+}
+
+/*
+callers hold f.mu
+*/
+func (f *fakeGithub) createIssue(repo, title, body string, labels, assignees []string) *fakeIssue {
+	r := f.repos[repo]
+	now := time.Now()
+	issue := &fakeIssue{
+		Number:    len(r.issues) + 1,
+		Title:     title,
+		Body:      body,
+		State:     "open",
+		Labels:    []fakeLabel{},
+		Assignees: []fakeUser{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	issue.NodeID = fmt.Sprintf("I_%s_%d", strings.Replace(repo, "/", "_", -1), issue.Number)
+	issue.HTMLURL = fmt.Sprintf("%s/%s/issues/%d", f.server.URL, repo, issue.Number)
+	for _, name := range labels {
+		f.labelNamed(r, name)
+		issue.Labels = append(issue.Labels, fakeLabel{Name: name})
+	}
+	for _, login := range assignees {
+		issue.Assignees = append(issue.Assignees, fakeUser{Login: login})
+	}
+	r.issues = append(r.issues, issue)
+	return issue
+}
+
+func (issue *fakeIssue) labelNames() []string {
+	names := []string{}
+	for _, label := range issue.Labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+func (issue *fakeIssue) assigneeLogins() []string {
+	logins := []string{}
+	for _, user := range issue.Assignees {
+		logins = append(logins, user.Login)
+	}
+	return logins
+}
+
+func removeFakeUser(users []fakeUser, login string) []fakeUser {
+	kept := []fakeUser{}
+	for _, user := range users {
+		if !strings.EqualFold(user.Login, login) {
+			kept = append(kept, user)
+		}
+	}
+	return kept
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeFakeError(w http.ResponseWriter, status int, message string) {
+	writeFakeJSON(w, status, map[string]string{"message": message, "documentation_url": "https://docs.github.com/rest"})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// github rejects app JWTs valid for more than 10 minutes; iat is backdated to allow for clock drift
+	appJWTLifetime = 9 * time.Minute
+	appJWTBackdate = time.Minute
+)
+
+/*
+returns a client authenticated as the installation of the Github App on owner. If installationID is 0 the
+installation is looked up by owner (organization first, then user). The installation token is minted on first use
+and re-minted automatically shortly before it expires (after an hour)
+*/
+func getGithubAppClient(ctx context.Context, endpoint GithubEndpoint, appID, installationID int64, privateKeyPEM []byte, owner string, limiter *rateLimiter, key string) (*github.Client, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := endpoint.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	/* the app itself and its installation have separate quotas */
+	appCtx := context.WithValue(context.Background(), oauth2.HTTPClient, limiter.wrap(httpClient, key+" app"))
+	appClient, err := endpoint.newClient(oauth2.NewClient(appCtx, oauth2.ReuseTokenSource(nil, &appJWTSource{appID: appID, key: privateKey})))
+	if err != nil {
+		return nil, err
+	}
+	if installationID == 0 {
+		installationID, err = findInstallation(ctx, appClient, owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ts := oauth2.ReuseTokenSource(nil, &installationTokenSource{appClient: appClient, installationID: installationID})
+	transportCtx := context.WithValue(context.Background(), oauth2.HTTPClient, limiter.wrap(httpClient, key))
+	return endpoint.newClient(oauth2.NewClient(transportCtx, ts))
+}
+
+func findInstallation(ctx context.Context, appClient *github.Client, owner string) (int64, error) {
+	installation, resp, err := appClient.Apps.FindOrganizationInstallation(ctx, owner)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		installation, resp, err = appClient.Apps.FindUserInstallation(ctx, owner)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("looking up the Github App installation on %s: %w", owner, err)
+	}
+	return installation.GetID(), nil
+}
+
+// appJWTSource authenticates as the Github App itself, which is only needed to look up installations and mint
+// installation tokens
+type appJWTSource struct {
+	appID int64
+	key   *rsa.PrivateKey
+}
+
+func (s *appJWTSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	jwt, err := signAppJWT(s.appID, s.key, now)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: jwt, TokenType: "Bearer", Expiry: now.Add(appJWTLifetime)}, nil
+}
+
+// installationTokenSource mints installation access tokens, wrapped in oauth2.ReuseTokenSource so a token is reused
+// until it is about to expire
+type installationTokenSource struct {
+	appClient      *github.Client
+	installationID int64
+}
+
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.appClient.Apps.CreateInstallationToken(context.Background(), s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("minting a token for Github App installation %d: %w", s.installationID, err)
+	}
+	return &oauth2.Token{AccessToken: token.GetToken(), TokenType: "token", Expiry: token.GetExpiresAt()}, nil
+}
+
+/*
+RS256 JWT as described in https://docs.github.com/en/developers/apps/authenticating-with-github-apps
+*/
+func signAppJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-appJWTBackdate).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+/*
+github hands out PKCS#1 keys, but accept PKCS#8 too
+*/
+func parsePrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("private key of the Github App is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key of the Github App: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key of the Github App is not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Github App", func() {
+	ctx := context.Background()
+	var key *rsa.PrivateKey
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	pemEncoded := func(blockType string, der []byte) []byte {
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	}
+
+	Context("parsePrivateKey", func() {
+		It("reads the PKCS#1 keys github hands out", func() {
+			parsed, err := parsePrivateKey(pemEncoded("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.Equal(key)).To(BeTrue())
+		})
+
+		It("reads PKCS#8 keys", func() {
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			Expect(err).NotTo(HaveOccurred())
+			parsed, err := parsePrivateKey(pemEncoded("PRIVATE KEY", der))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.Equal(key)).To(BeTrue())
+		})
+
+		It("rejects what is not a PEM encoded RSA key", func() {
+			_, err := parsePrivateKey([]byte("not a key"))
+			Expect(err).To(MatchError(ContainSubstring("not PEM encoded")))
+
+			ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+			der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = parsePrivateKey(pemEncoded("PRIVATE KEY", der))
+			Expect(err).To(MatchError(ContainSubstring("not an RSA key")))
+		})
+	})
+
+	It("signs a JWT for the app, backdated and valid for less than 10 minutes", func() {
+		now := time.Unix(1700000000, 0)
+		jwt, err := signAppJWT(42, key, now)
+		Expect(err).NotTo(HaveOccurred())
+		parts := strings.Split(jwt, ".")
+		Expect(parts).To(HaveLen(3))
+
+		header, err := base64.RawURLEncoding.DecodeString(parts[0])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(header).To(MatchJSON(`{"alg": "RS256", "typ": "JWT"}`))
+		claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claims).To(MatchJSON(fmt.Sprintf(`{"iat": %d, "exp": %d, "iss": 42}`, now.Unix()-60, now.Unix()+9*60)))
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		Expect(err).NotTo(HaveOccurred())
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		Expect(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature)).To(Succeed())
+	})
+
+	Context("getGithubAppClient", func() {
+		var appID int64
+		var owner, repo string
+
+		BeforeEach(func() {
+			appID = time.Now().UnixNano()
+			owner = fmt.Sprintf("owner-%d", appID)
+			repo = owner + "/repo"
+			fakeServer.addIssue(repo, "readable by the installation", "")
+		})
+
+		readIssue := func(installationID int64) error {
+			privateKeyPEM := pemEncoded("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+			githubClient, err := getGithubAppClient(ctx, fakeServer.endpoint(), appID, installationID, privateKeyPEM, owner, newRateLimiter(0), "app")
+			if err != nil {
+				return err
+			}
+			issue, _, err := githubClient.Issues.Get(ctx, owner, "repo", 1)
+			if err == nil && issue.GetTitle() != "readable by the installation" {
+				err = fmt.Errorf("read issue %q", issue.GetTitle())
+			}
+			return err
+		}
+
+		It("looks up the installation on an organization", func() {
+			installationID := fakeServer.installApp(appID, &key.PublicKey, owner, true)
+			Expect(readIssue(0)).To(Succeed())
+			Expect(fakeServer.requestCount("GET", "orgs/"+owner+"/installation")).To(Equal(1))
+			Expect(fakeServer.requestCount("GET", "users/"+owner+"/installation")).To(Equal(0))
+			Expect(fakeServer.requestCount("POST", fmt.Sprintf("app/installations/%d/access_tokens", installationID))).To(Equal(1))
+		})
+
+		It("falls back to the installation on a user", func() {
+			installationID := fakeServer.installApp(appID, &key.PublicKey, owner, false)
+			Expect(readIssue(0)).To(Succeed())
+			Expect(fakeServer.requestCount("GET", "orgs/"+owner+"/installation")).To(Equal(1))
+			Expect(fakeServer.requestCount("GET", "users/"+owner+"/installation")).To(Equal(1))
+			Expect(fakeServer.requestCount("POST", fmt.Sprintf("app/installations/%d/access_tokens", installationID))).To(Equal(1))
+		})
+
+		It("uses the installation ID of the Secret without looking it up", func() {
+			installationID := fakeServer.installApp(appID, &key.PublicKey, owner, true)
+			Expect(readIssue(installationID)).To(Succeed())
+			Expect(fakeServer.requestCount("GET", "orgs/"+owner+"/installation")).To(Equal(0))
+		})
+
+		It("fails when the app is not installed on the owner", func() {
+			fakeServer.installApp(appID, &key.PublicKey, "someone-else-"+owner, true)
+			Expect(readIssue(0)).To(MatchError(ContainSubstring("looking up the Github App installation on " + owner)))
+		})
+
+		It("is refused by github when signed with another key", func() {
+			otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			fakeServer.installApp(appID, &otherKey.PublicKey, owner, true)
+			Expect(readIssue(0)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// githubAPIURL is the API of github.com, the endpoint of an empty GithubEndpoint
+const githubAPIURL = "https://api.github.com/"
+
+// GithubEndpoint is the Github instance the operator talks to: github.com if BaseURL is empty, otherwise a
+// Github Enterprise Server
+type GithubEndpoint struct {
+	// BaseURL of the API, e.g. https://github.example.com/api/v3/
+	BaseURL string
+	// UploadURL of the API, derived from BaseURL if empty
+	UploadURL string
+	// CABundle holds PEM encoded certificates trusted in addition to the system ones (for self-signed GHES certificates)
+	CABundle []byte
+}
+
+/*
+the endpoint of target: spec.baseURL and spec.uploadURL (trusting the CA bundle of its credentials) if set,
+otherwise the operator-wide default endpoint
+*/
+func endpointFor(target githubTarget, defaultEndpoint GithubEndpoint, caBundle []byte) GithubEndpoint {
+	if target.baseURL == "" {
+		return defaultEndpoint
+	}
+	endpoint := GithubEndpoint{BaseURL: target.baseURL, UploadURL: target.uploadURL, CABundle: caBundle}
+	if len(endpoint.CABundle) == 0 {
+		endpoint.CABundle = defaultEndpoint.CABundle
+	}
+	return endpoint
+}
+
+/*
+target talks to the operator-wide endpoint: spec.baseURL (and spec.uploadURL) are empty or point to it. Only then
+may the operator-wide credentials be sent, otherwise anyone able to create an object could point spec.baseURL to a
+host of theirs and collect the operator's token
+*/
+func isDefaultEndpoint(target githubTarget, defaultEndpoint GithubEndpoint) bool {
+	if target.baseURL == "" {
+		return true
+	}
+	defaultBaseURL := defaultEndpoint.BaseURL
+	if defaultBaseURL == "" {
+		defaultBaseURL = githubAPIURL
+	}
+	if !sameURL(target.baseURL, defaultBaseURL) {
+		return false
+	}
+	return target.uploadURL == "" || sameURL(target.uploadURL, defaultEndpoint.UploadURL)
+}
+
+/*
+a and b are the same URL, ignoring the case of scheme and host and a trailing slash
+*/
+func sameURL(a, b string) bool {
+	urlA, errA := url.Parse(a)
+	urlB, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(urlA.Scheme, urlB.Scheme) && strings.EqualFold(urlA.Host, urlB.Host) &&
+		strings.TrimSuffix(urlA.Path, "/") == strings.TrimSuffix(urlB.Path, "/")
+}
+
+/*
+the http client carrying requests to the endpoint, trusting the endpoint's CA bundle
+*/
+func (e GithubEndpoint) httpClient() (*http.Client, error) {
+	if len(e.CABundle) == 0 {
+		return http.DefaultClient, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(e.CABundle) {
+		return nil, fmt.Errorf("CA bundle of %s contains no PEM encoded certificate", e.BaseURL)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
+}
+
+/*
+github.NewClient for github.com, github.NewEnterpriseClient for a Github Enterprise Server
+*/
+func (e GithubEndpoint) newClient(httpClient *http.Client) (*github.Client, error) {
+	if e.BaseURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+	uploadURL := e.UploadURL
+	if uploadURL == "" {
+		base, err := url.Parse(e.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		uploadURL = base.Scheme + "://" + base.Host + "/" // NewEnterpriseClient appends api/uploads/
+	}
+	return github.NewEnterpriseClient(e.BaseURL, uploadURL, httpClient)
+}
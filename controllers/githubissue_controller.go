@@ -6,18 +6,26 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/google/go-github/v35/github"
 	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
 	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"log"
 	"os"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil" //finalizer related
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strings"
+	"time"
 
 	"io/ioutil"
-	"net/http"
 	// "github.com/google/go-github/github" // with go modules disabled
 )
 
@@ -26,15 +34,46 @@ type GithubIssueReconciler struct {
 	client.Client //type embedding
 	Log           logr.Logger
 	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	// ListPageSize is the number of issues requested per page when listing a repository (default 100, Github's maximum)
+	ListPageSize int
+	// ListMaxIssues bounds how many issues are listed per repository (default 10000)
+	ListMaxIssues int
+	// CacheInterval is how often the shared issue list of a repository is refreshed from Github (default 1m)
+	CacheInterval time.Duration
+	// DefaultCredentialsSecret holds the credentials (a token or a Github App) of objects without spec.credentialsRef.
+	// If empty, the TOKEN environment variable is used
+	DefaultCredentialsSecret types.NamespacedName
+	// DefaultEndpoint is the Github instance of objects without spec.baseURL (github.com if empty)
+	DefaultEndpoint GithubEndpoint
+	// RateLimitThreshold is the remaining quota of a credential below which its requests are paused until the quota resets
+	RateLimitThreshold int
+	// IssueTracker returns the tracker an object's issue lives in, e.g. a fake in tests. If nil, issues live on Github
+	IssueTracker IssueTrackerFunc
+	// Clients is shared by all reconcilers, so they reuse one client and one rate limit per credential. If nil, it is
+	// built from DefaultCredentialsSecret, DefaultEndpoint and RateLimitThreshold
+	Clients *GithubClients
+
+	issues *issueCache
+	drift  *driftTracker
 }
 
 const finalizerName = "training.redhat.com/finalizer" // domain/name-of-custom-finalizer
 
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+const (
+	defaultListPageSize  = 100
+	defaultListMaxIssues = 10000
+)
+
 func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := r.Log.WithValues("githubissue_name", req.NamespacedName)
 	logger.Info("**************START LOGIC**************")
-	/* AUTHENTICATION */
-	githubClient, ctx1 := getGithubClient()
 
 	/* Get object from k8s cluster */
 	ghissue := g.GithubIssue{}
@@ -42,6 +81,7 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	if err != nil {
 		if errors.IsNotFound(err) { //err status is 404 -> return with nil error (don't requeue)
 			log404(logger)
+			r.drift.forget(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 		logger.Error(err, "Error reading the object -> Requeue the request.")
@@ -55,16 +95,81 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, err
 		}
 	}
-	owner, repo := splitOwnerRepo(ghissue.Spec.Repo)
-	allRepoIssues, err := getListOfIssues(githubClient, ctx1, owner, repo, logger)
+	/* refuse what the provider can not do before touching the issue. On deletion handleDeletionIfIssueFound falls back
+	to what the provider can do, so the finalizer is still removed */
+	if err = validateSpec(&ghissue); err != nil && ghissue.ObjectMeta.DeletionTimestamp.IsZero() {
+		logger.Info("Invalid spec", "reason", err.Error())
+		setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonInvalidSpec, err.Error())
+		setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonInvalidSpec, err.Error())
+		r.Recorder.Event(&ghissue, corev1.EventTypeWarning, eventInvalidSpec, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, nil, &ghissue)
+	}
+	/* AUTHENTICATION */
+	issueTracker, err := r.trackerFor(ctx, &ghissue, logger)
+	if err != nil && !ghissue.ObjectMeta.DeletionTimestamp.IsZero() && isUnrecoverableOnDeletion(err, issueTarget(&ghissue)) {
+		r.Recorder.Eventf(&ghissue, corev1.EventTypeWarning, eventIssueAbandoned,
+			"Removed the finalizer without handling issue #%d (deletionPolicy %s): %v", ghissue.Status.Number, ghissue.Spec.DeletionPolicy, err)
+		controllerutil.RemoveFinalizer(&ghissue, finalizerName)
+		return ctrl.Result{}, r.Update(ctx, &ghissue)
+	}
 	if err != nil {
-		logger.Error(err, "While trying to get repo's list of issues")
+		logger.Error(err, "While trying to read the Github credentials")
+		reason, retry := credentialsFailure(err)
+		setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, reason, err.Error())
+		setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+		r.Recorder.Event(&ghissue, corev1.EventTypeWarning, eventCredentialsError, err.Error())
+		_ = r.updateStatus(ctx, nil, &ghissue)
+		return ctrl.Result{}, retry
+	}
+	ctx1 := context.Background()
+	repo := ghissue.Spec.Repo
+
+	/* resolve spec.milestoneRef before touching the issue, so the issue is created with its milestone */
+	milestone, milestoneReady, err := r.resolveMilestone(ctx, &ghissue)
+	if err != nil {
+		logger.Error(err, "While trying to read the GithubMilestone")
 		return ctrl.Result{}, err
 	}
+	if !milestoneReady && ghissue.ObjectMeta.DeletionTimestamp.IsZero() {
+		logger.Info("Waiting for the milestone", "milestoneRef", ghissue.Spec.MilestoneRef.Name)
+		setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonMilestoneNotReady, "Waiting for the milestone, see the MilestoneResolved condition")
+		setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonMilestoneNotReady, "Waiting for the milestone, see the MilestoneResolved condition")
+		return ctrl.Result{}, r.updateStatus(ctx, nil, &ghissue)
+	}
 
 	/* check if issue exists in github repo */
-	issue, err := searchIssueByTitle(allRepoIssues, ghissue.Spec.Title)
+	pushSpec := true // false if spec.syncDirection does not allow to modify the issue
+	issue, err := r.findIssue(issueTracker, ctx1, &ghissue, logger)
 	if err != nil {
+		logger.Error(err, "While trying to find the issue on Github")
+		return r.reconcileFailed(ctx, &ghissue, g.ReasonLookupFailed, err)
+	}
+	setGithubReachableCondition(&ghissue, nil)
+	if issue != nil && !mayModify(issue, &ghissue) {
+		/* the issue is owned by another GithubIssue object -> never touch it */
+		message := fmt.Sprintf("Issue #%d is owned by the GithubIssue %s, set adoptionPolicy Always to take it over", issue.Number, issueOwner(issue))
+		if !ghissue.ObjectMeta.DeletionTimestamp.IsZero() {
+			controllerutil.RemoveFinalizer(&ghissue, finalizerName)
+			err = r.Update(ctx, &ghissue)
+			if err != nil {
+				logger.Error(err, "r.Update() failed")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		logger.Info("Refusing to modify an issue owned by another object", "number", issue.Number, "owner", issueOwner(issue))
+		setCondition(&ghissue, g.ConditionAdopted, metav1.ConditionFalse, g.ReasonNotOwned, message)
+		setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonNotOwned, message)
+		setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonNotOwned, message)
+		r.Recorder.Event(&ghissue, corev1.EventTypeWarning, eventIssueNotOwned, message)
+		return ctrl.Result{}, r.updateStatus(ctx, nil, &ghissue)
+	}
+	if issue != nil && issue.Number != ghissue.Status.Number {
+		reason, message := adoptionReason(issue, &ghissue)
+		setCondition(&ghissue, g.ConditionAdopted, metav1.ConditionTrue, reason, message)
+		r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventIssueAdopted, "Adopted existing issue #%d %s", issue.Number, issue.HTMLURL)
+	}
+	if issue == nil {
 		/*issue not found*/
 		if !ghissue.ObjectMeta.DeletionTimestamp.IsZero() {
 			/* DeletionTimestamp Not Zero && No issue on Github */
@@ -77,21 +182,42 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, nil
 		}
 		/* k8s object is not being deleted */
-		issue, err = createIssueOnGithub(githubClient, ctx1, owner, repo, &ghissue, logger)
+		if ghissue.Spec.IssueNumber != 0 {
+			/* bind to the named issue only, never create a replacement */
+			message := fmt.Sprintf("Issue #%d named by spec.issueNumber does not exist", ghissue.Spec.IssueNumber)
+			setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonIssueNotFound, message)
+			setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonIssueNotFound, message)
+			return ctrl.Result{}, r.updateStatus(ctx, nil, &ghissue)
+		}
+		if meta.IsStatusConditionTrue(ghissue.Status.Conditions, g.ConditionIssueListTruncated) {
+			/* the issue may exist beyond the listing bound -> creating it could file a duplicate */
+			logger.Info("Issue not found in the listed part of the repository, not creating it", "maxIssues", r.issueListOptions().MaxIssues)
+			setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonMaxIssuesReached, "Issue could not be searched in the entire repository, see the IssueListTruncated condition")
+			setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonMaxIssuesReached, "Issue could not be searched in the entire repository, see the IssueListTruncated condition")
+			return ctrl.Result{}, r.updateStatus(ctx, nil, &ghissue)
+		}
+		rejected := ghissue.Status.RejectedAssignees
+		issue, err = createIssue(issueTracker, ctx1, &ghissue, milestone)
 		if err != nil {
 			logger.Error(err, "While trying to create issue on Github")
-			return ctrl.Result{}, err
+			return r.reconcileFailed(ctx, &ghissue, g.ReasonCreateFailed, err)
 		}
+		r.issues.invalidate(repo)
+		r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventIssueCreated, "Created issue #%d %s", issue.Number, issue.HTMLURL)
+		r.assigneesRejected(&ghissue, rejected)
+		setCondition(&ghissue, g.ConditionAdopted, metav1.ConditionFalse, g.ReasonIssueCreated,
+			fmt.Sprintf("Issue #%d was created by the operator", issue.Number))
 		/*************************************************************************************************/
 	} else {
 		/*issue was found*/
 		if !ghissue.ObjectMeta.DeletionTimestamp.IsZero() {
 			/* DeletionTimestamp Not Zero -> delete */
-			err = handleDeletionIfIssueFound(githubClient, ctx1, owner, repo, issue, &ghissue, logger)
+			err = handleDeletionIfIssueFound(issueTracker, ctx1, issue, &ghissue, r.Recorder, logger)
 			if err != nil {
 				logger.Error(err, "While trying to delete issue on Github")
-				return ctrl.Result{}, err
+				return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonDeletionFailed, err)
 			}
+			r.issues.invalidate(repo)
 			err = r.Update(ctx, &ghissue)
 			if err != nil {
 				logger.Error(err, " r.Update() failed ")
@@ -100,17 +226,101 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 			return ctrl.Result{}, nil
 		}
 		/* k8s object is not being deleted */
-		if !isDescriptionEqual(issue, &ghissue) {
-			_, err = updateDescriptionOnGithub(githubClient, ctx1, owner, repo, *issue.Number, &ghissue, logger)
-			if err != nil {
-				logger.Error(err, "While trying to update issue on Github")
-				return ctrl.Result{}, err
+		pushSpec, err = r.syncFromGithub(ctx, issue, &ghissue, logger)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if meta.IsStatusConditionTrue(ghissue.Status.Conditions, g.ConditionSyncConflict) {
+			setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonBothSidesChanged, "See the SyncConflict condition")
+			setCondition(&ghissue, g.ConditionReady, metav1.ConditionFalse, g.ReasonBothSidesChanged, "See the SyncConflict condition")
+			return ctrl.Result{}, r.updateStatus(ctx, issue, &ghissue)
+		}
+		if ghissue.Spec.DriftPolicy == g.DriftPolicyReport {
+			/* drift is an edit made on github since the last sync; edits of the spec are still applied */
+			var drift []g.FieldDrift
+			if last := ghissue.Status.LastAppliedHash; last != "" && githubFields(issue, &ghissue).hash() != last {
+				drift = detectDrift(issue, &ghissue)
+			}
+			setDrift(&ghissue, drift)
+			r.drift.set(req.NamespacedName, ghissue.Spec.Repo, len(drift) > 0)
+			pushSpec = pushSpec && len(drift) == 0
+		} else {
+			clearDrift(&ghissue)
+			r.drift.forget(req.NamespacedName)
+		}
+		if pushSpec {
+			if !isTitleEqual(issue, &ghissue) || !isDescriptionEqual(issue, &ghissue) {
+				updated, err := updateDescription(issueTracker, ctx1, issue.Number, &ghissue)
+				if err != nil {
+					logger.Error(err, "While trying to update issue on Github")
+					return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonUpdateFailed, err)
+				}
+				issue = updated
+				r.issues.invalidate(repo)
+				r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventDescriptionUpdated, "Updated title and description of issue #%d", issue.Number)
+			}
+			if labels := desiredLabels(issue, &ghissue); !isLabelsEqual(issue, labels) {
+				err = issueTracker.SetLabels(ctx1, repo, issue.Number, labels)
+				if err != nil {
+					logger.Error(err, "While trying to update labels on Github")
+					return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonUpdateFailed, err)
+				}
+				r.issues.invalidate(repo)
+				r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventLabelsUpdated, "Set labels of issue #%d to %v", issue.Number, labels)
+			}
+			if !isAssigneesEqual(issue, &ghissue) {
+				rejected := ghissue.Status.RejectedAssignees
+				written, err := updateAssignees(issueTracker, ctx1, issue, &ghissue)
+				if written {
+					r.issues.invalidate(repo)
+					r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventAssigneesUpdated, "Updated assignees of issue #%d", issue.Number)
+				}
+				if err != nil {
+					logger.Error(err, "While trying to update assignees on Github")
+					return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonUpdateFailed, err)
+				}
+				r.assigneesRejected(&ghissue, rejected)
 			}
+			if desired := desiredMilestone(issue, &ghissue, milestone); issue.Milestone != desired {
+				updated, err := issueTracker.Update(ctx1, repo, issue.Number, tracker.IssueUpdate{Milestone: &desired})
+				if err != nil {
+					logger.Error(err, "While trying to update milestone on Github")
+					return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonUpdateFailed, err)
+				}
+				issue = updated
+				r.issues.invalidate(repo)
+				r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventMilestoneAssigned, "Set milestone of issue #%d to %d", issue.Number, desired)
+			}
+		}
+	}
+	if desired := desiredState(&ghissue); pushSpec && issue.State != desired {
+		updated, err := updateState(issueTracker, ctx1, repo, issue.Number, desired, ghissue.Spec.StateReason)
+		if err != nil {
+			logger.Error(err, "While trying to update issue state on Github")
+			return r.writeFailed(ctx, &ghissue, issue.Number, g.ReasonUpdateFailed, err)
 		}
+		issue = updated
+		r.issues.invalidate(repo)
+		if desired == "closed" {
+			r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventIssueClosed, "Closed issue #%d", issue.Number)
+		} else {
+			r.Recorder.Eventf(&ghissue, corev1.EventTypeNormal, eventIssueReopened, "Reopened issue #%d", issue.Number)
+		}
+	}
+	if pushSpec {
+		ghissue.Status.ManagedLabels = ghissue.Spec.Labels
+		ghissue.Status.Milestone = milestone
+	}
+	if len(ghissue.Status.Drift) == 0 {
+		setLastSynced(issue, &ghissue, specFields(&ghissue)) // both sides agree now
+	}
+	setSyncedConditions(&ghissue, issue)
+	if len(ghissue.Status.Drift) > 0 {
+		setCondition(&ghissue, g.ConditionSynced, metav1.ConditionFalse, g.ReasonDriftDetected, "Issue was edited on github, see the Drifted condition")
 	}
 	/*important! call the below 3 lines of code only ONCE in entire reconcile. Avoid redundant calls!*/
 	err = r.updateStatus(ctx, issue, &ghissue)
-	if err != nil{
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 	return ctrl.Result{}, nil //no error
@@ -119,14 +329,97 @@ func (r *GithubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 // SetupWithManager sets up the controller with the Manager.
 func (r *GithubIssueReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	/* this method tells the controller "you are tracking resources of type GitHubIssue" */
+	r.issues = newIssueCache(r.CacheInterval)
+	r.drift = newDriftTracker()
+	if r.Clients == nil {
+		r.Clients = NewGithubClients(r.DefaultCredentialsSecret, r.DefaultEndpoint, r.RateLimitThreshold)
+	}
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &g.GithubIssue{}, credentialsRefNameField, func(obj client.Object) []string {
+		ref := obj.(*g.GithubIssue).Spec.CredentialsRef
+		if ref == nil {
+			return nil
+		}
+		return []string{ref.Name}
+	})
+	if err != nil {
+		return err
+	}
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &g.GithubIssue{}, milestoneRefNameField, func(obj client.Object) []string {
+		ref := obj.(*g.GithubIssue).Spec.MilestoneRef
+		if ref == nil {
+			return nil
+		}
+		return []string{ref.Name}
+	})
+	if err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&g.GithubIssue{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(objectsForSecret(r.Client, r.Log, func() client.ObjectList { return &g.GithubIssueList{} }))).
+		Watches(&source.Kind{Type: &g.GithubMilestone{}}, handler.EnqueueRequestsFromMapFunc(r.githubIssuesForMilestone)).
 		Complete(r)
 }
 
-func (r *GithubIssueReconciler)  updateStatus(ctx context.Context, issue *github.Issue, ghissue *g.GithubIssue)  error{
-	ghissue.Status.State = *issue.State
-	ghissue.Status.LastUpdateTimestamp = issue.UpdatedAt.String()
+/*
+record a failed step of the reconcile in the status conditions, then return err so the request is requeued
+*/
+func (r *GithubIssueReconciler) reconcileFailed(ctx context.Context, ghissue *g.GithubIssue, reason string, err error) (ctrl.Result, error) {
+	setFailedConditions(ghissue, reason, err)
+	recordFailureEvent(r.Recorder, ghissue, reason, err)
+	_ = r.updateStatus(ctx, nil, ghissue) // logged by updateStatus, the original error is the one to report
+	if after, ok := rateLimitRequeue(err); ok {
+		return ctrl.Result{RequeueAfter: after}, nil // retrying earlier would only burn the quota
+	}
+	return ctrl.Result{}, err
+}
+
+/*
+emits the AssigneesRejected event when the tracker rejected assignees other than the previously rejected ones, so
+the same rejection is reported once instead of on every resync
+*/
+func (r *GithubIssueReconciler) assigneesRejected(ghissue *g.GithubIssue, previous []string) {
+	if rejected := ghissue.Status.RejectedAssignees; len(rejected) > 0 && !equalNameSets(rejected, previous) {
+		r.Recorder.Eventf(ghissue, corev1.EventTypeWarning, eventAssigneesRejected, "Github rejected the assignees %v", rejected)
+	}
+}
+
+/*
+reports a failed write to issue number. If the tracker says the issue is gone (404 or 410: deleted, or transferred to
+another repository) it is evicted from the cache, so the retry looks it up with Get and falls back to the other lookups
+instead of finding the stale cached copy again
+*/
+func (r *GithubIssueReconciler) writeFailed(ctx context.Context, ghissue *g.GithubIssue, number int, reason string, err error) (ctrl.Result, error) {
+	if isIssueGone(err) {
+		r.issues.evict(ghissue.Spec.Repo, number)
+	}
+	return r.reconcileFailed(ctx, ghissue, reason, err)
+}
+
+func (r *GithubIssueReconciler) issueListOptions() tracker.ListOptions {
+	opts := tracker.ListOptions{PageSize: r.ListPageSize, MaxIssues: r.ListMaxIssues}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultListPageSize
+	}
+	if opts.MaxIssues <= 0 {
+		opts.MaxIssues = defaultListMaxIssues
+	}
+	return opts
+}
+
+/*
+issue may be nil if there is no github issue (yet) -> only the conditions are updated
+*/
+func (r *GithubIssueReconciler) updateStatus(ctx context.Context, issue *tracker.Issue, ghissue *g.GithubIssue) error {
+	if issue != nil {
+		ghissue.Status.State = issue.State
+		ghissue.Status.LastUpdateTimestamp = issue.UpdatedAt.String()
+		ghissue.Status.Number = issue.Number
+		ghissue.Status.NodeID = issue.NodeID
+		ghissue.Status.Key = issue.Key
+		ghissue.Status.HTMLURL = issue.HTMLURL
+	}
+	ghissue.Status.ObservedGeneration = ghissue.Generation
 	err := r.Status().Update(ctx, ghissue)
 	if err != nil {
 		r.Log.Error(err, "((GithubIssueReconciler)r).Status().Update() failed ")
@@ -135,131 +428,238 @@ func (r *GithubIssueReconciler)  updateStatus(ctx context.Context, issue *github
 	return nil
 }
 
-func closeIssueOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, issue *github.Issue, ghissue *g.GithubIssue, logger logr.Logger) error {
-	/*
-	* delete any external resources associated with the ghissue
-	* Ensure that delete implementation is idempotent and safe to invoke multiple times for same object.*/
-	if issue == nil {
-		return fmt.Errorf("closeIssueOnGithub() was passed nil issue param (you can't close an issue that never existed)")
-	}
-	issueReq := &github.IssueRequest{
-		Title: github.String(ghissue.Spec.Title),
-		Body:  github.String(ghissue.Spec.Desc),
-		State: github.String("closed"),
-	}
-	issue, resp, err := githubClient.Issues.Edit(ctx, owner, repo, *issue.Number, issueReq)
-	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
-		body, _ := ioutil.ReadAll(resp.Body)
-		logger = logger.WithName("closeIssueOnGithub()")
-		logger.Error(err, "Deleting github issue failed", "Github api response code is", resp.StatusCode, "The response body is", string(body)) //print body as it may contain hints in case of errors
-		return err
+/*
+find the issue bound to ghissue. Once an issue was created or adopted its number is recorded in the status,
+so later reconciles look it up by number and are not affected by title changes or other issues with the same title.
+spec.issueNumber takes precedence over the status and is never replaced by another issue.
+Otherwise the issue carrying ghissue's ownership marker is searched, and title search (as far as adoptionPolicy
+allows) is the fallback for first-time adoption; it records in the IssueListTruncated condition whether the
+entire repository was searched. All lookups are served from the repository's shared issue cache.
+Returns nil issue (and nil error) if there is no such issue.
+*/
+func (r *GithubIssueReconciler) findIssue(issueTracker tracker.IssueTracker, ctx context.Context, ghissue *g.GithubIssue, logger logr.Logger) (*tracker.Issue, error) {
+	opts := r.issueListOptions()
+	allRepoIssues, truncated, err := r.issues.list(credentialsScope(issueTarget(ghissue)), issueTracker, ctx, ghissue.Spec.Repo, opts)
+	if err != nil {
+		logger.Error(err, "While trying to get repo's list of issues")
+		return nil, err
 	}
-	return nil
+	number := ghissue.Status.Number
+	if ghissue.Spec.IssueNumber != 0 {
+		number = ghissue.Spec.IssueNumber
+	}
+	if number != 0 {
+		if issue := searchIssueByNumber(allRepoIssues, number); issue != nil {
+			return issue, nil
+		}
+		/* not in the cached listing (beyond the listing bound, or gone) -> ask the tracker directly */
+		issue, err := issueTracker.Get(ctx, ghissue.Spec.Repo, number)
+		if err != nil || issue != nil || ghissue.Spec.IssueNumber != 0 {
+			return issue, err
+		}
+		/* the recorded issue is gone (deleted or transferred) -> fall back to title search */
+		logger.Info("Issue recorded in status no longer exists on Github", "number", number)
+	}
+	setListTruncatedCondition(ghissue, truncated, opts)
+	if issue := searchOwnedIssue(allRepoIssues, ghissue); issue != nil {
+		return issue, nil
+	}
+	issue, err := searchIssueByTitle(allRepoIssues, ghissue.Spec.Title, func(issue *tracker.Issue) bool { return adoptableByTitle(issue, ghissue) })
+	if err != nil {
+		return nil, nil
+	}
+	return issue, nil
 }
 
-func getListOfIssues(githubClient *github.Client, ctx context.Context, owner, repo string, logger logr.Logger) ([]*github.Issue, error) {
-	//opts := githubClient.Issues.IssueListByRepoOptions
-	opts := github.IssueListByRepoOptions{
-		State: "all",
-	}
-	issues, resp, err := githubClient.Issues.ListByRepo(ctx, owner, repo, &opts)
-	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
-		//log body as it may contain hints in case of errors
-		body, _ := ioutil.ReadAll(resp.Body)
-		logger = logger.WithName("getListOfIssues()")
-		logger.Error(err, "Reading the list of issues from github repo failed", "Github api response code is", resp.StatusCode, "The response body is", string(body))
-		return nil, err
+func createIssue(issueTracker tracker.IssueTracker, ctx context.Context, githubIssueObj *g.GithubIssue, milestone int) (*tracker.Issue, error) {
+	issueReq := tracker.IssueRequest{
+		Title:     githubIssueObj.Spec.Title,
+		Body:      issueBody(githubIssueObj),
+		Labels:    githubIssueObj.Spec.Labels,
+		Milestone: milestone,
+	}
+	if len(githubIssueObj.Spec.Assignees) > 0 {
+		/* github fails the whole creation on an invalid assignee -> only send the valid ones */
+		valid, rejected, err := issueTracker.ValidateAssignees(ctx, githubIssueObj.Spec.Repo, githubIssueObj.Spec.Assignees)
+		if err != nil {
+			return nil, err
+		}
+		githubIssueObj.Status.RejectedAssignees = rejected
+		issueReq.Assignees = valid
+	} else {
+		githubIssueObj.Status.RejectedAssignees = nil
 	}
-	return issues, nil
+	githubIssueObj.Status.ValidatedAssignees = githubIssueObj.Spec.Assignees
+	return issueTracker.Create(ctx, githubIssueObj.Spec.Repo, issueReq)
 }
 
-func createIssueOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, githubIssueObj *g.GithubIssue, logger logr.Logger) (*github.Issue, error) {
-	issueReq := &github.IssueRequest{
-		Title: github.String(githubIssueObj.Spec.Title),
-		Body:  github.String(githubIssueObj.Spec.Desc),
-		State: github.String("open"),
-	}
-	issue, resp, err := githubClient.Issues.Create(ctx, owner, repo, issueReq)
-	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
-		body, _ := ioutil.ReadAll(resp.Body)
-		logger = logger.WithName("createIssueOnGithub()")
-		logger.Error(err, "Creation of github issue failed", "Github api response code is", resp.StatusCode, "The response body is", string(body)) //print body as it may contain hints in case of errors
-		return nil, err
-	}
-	return issue, nil
+/*
+update the real world title and Description (aka Body)
+*/
+func updateDescription(issueTracker tracker.IssueTracker, ctx context.Context, number int, githubIssueObj *g.GithubIssue) (*tracker.Issue, error) {
+	title, body := githubIssueObj.Spec.Title, issueBody(githubIssueObj)
+	return issueTracker.Update(ctx, githubIssueObj.Spec.Repo, number, tracker.IssueUpdate{Title: &title, Body: &body})
 }
 
 /*
-update the real world Description (aka Body) */
-func updateDescriptionOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, number int, githubIssueObj *g.GithubIssue, logger logr.Logger) (*github.Issue, error) {
-	issueReq := &github.IssueRequest{
-		Title: github.String(githubIssueObj.Spec.Title),
-		Body:  github.String(githubIssueObj.Spec.Desc),
-	}
-	issue, resp, err := githubClient.Issues.Edit(ctx, owner, repo, number, issueReq)
-	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
-		body, _ := ioutil.ReadAll(resp.Body)
-		logger = logger.WithName("updateDescriptionOnGithub()")
-		logger.Error(err, "Updating github issue failed", "Github api response code is", resp.StatusCode, "The response body is", string(body)) //print body as it may contain hints in case of errors
-		return nil, err
+open or close the issue. stateReason (completed / not_planned) is only sent when closing
+*/
+func updateState(issueTracker tracker.IssueTracker, ctx context.Context, repo string, number int, state, stateReason string) (*tracker.Issue, error) {
+	if state == tracker.StateClosed {
+		return issueTracker.Close(ctx, repo, number, stateReason)
 	}
-	return issue, nil
+	return issueTracker.Reopen(ctx, repo, number)
 }
 
-func handleDeletionIfIssueFound(githubClient *github.Client, ctx1 context.Context, owner, repo string, issue *github.Issue, ghissue *g.GithubIssue, logger logr.Logger) error {
-	if stateClosed(issue) { // issue already closed on github
-		controllerutil.RemoveFinalizer(ghissue, finalizerName)
-	} else {
-		err := closeIssueOnGithub(githubClient, ctx1, owner, repo, issue, ghissue, logger) //handle external dependency
+/*
+handle the issue according to spec.deletionPolicy and remove our finalizer.
+Idempotent: a retried deletion skips the steps that are done already (the deletion comment is recorded in
+status.deletionCommentPosted, the status is written by reconcileFailed when a later step fails)
+*/
+func handleDeletionIfIssueFound(issueTracker tracker.IssueTracker, ctx1 context.Context, issue *tracker.Issue, ghissue *g.GithubIssue, recorder record.EventRecorder, logger logr.Logger) error {
+	policy := ghissue.Spec.DeletionPolicy
+	if policy == g.DeletionPolicyRetain {
+		controllerutil.RemoveFinalizer(ghissue, finalizerName) // leave the issue untouched (e.g. the object moves to another cluster)
+		recorder.Eventf(ghissue, corev1.EventTypeNormal, eventIssueRetained, "Left issue #%d untouched (deletionPolicy Retain)", issue.Number)
+		return nil
+	}
+	repo := ghissue.Spec.Repo
+	if !stateClosed(issue) { // issue not closed yet
+		if policy == g.DeletionPolicyCommentAndClose && ghissue.Status.DeletionCommentPosted != issue.Number {
+			err := issueTracker.Comment(ctx1, repo, issue.Number, deletionComment(ghissue))
+			if err != nil {
+				logger.Error(err, "While trying to comment on issue on Github")
+				return err
+			}
+			ghissue.Status.DeletionCommentPosted = issue.Number
+			recorder.Eventf(ghissue, corev1.EventTypeNormal, eventCommentPosted, "Posted closing comment on issue #%d", issue.Number)
+		}
+		_, err := issueTracker.Close(ctx1, repo, issue.Number, "") //handle external dependency
 		if err != nil {
 			logger.Error(err, "While trying to close issue on Github")
 			return err // if fail to delete the external dependency, return with error so that it can be retried
 		}
-		controllerutil.RemoveFinalizer(ghissue, finalizerName) // successful deletion of external resources -> remove our finalizer from the list
+		recorder.Eventf(ghissue, corev1.EventTypeNormal, eventIssueClosed, "Closed issue #%d", issue.Number)
 	}
+	if policy == g.DeletionPolicyLock && !issue.Locked && !canLock(ghissue.Spec.Provider) {
+		/* the spec was rejected while the object lived, closing is as close to locking as the provider gets */
+		recorder.Eventf(ghissue, corev1.EventTypeWarning, eventIssueNotLocked, "Closed issue #%d without locking it, provider %s can not lock issues", issue.Number, ghissue.Spec.Provider)
+	} else if policy == g.DeletionPolicyLock && !issue.Locked {
+		err := issueTracker.Lock(ctx1, repo, issue.Number)
+		if err != nil {
+			logger.Error(err, "While trying to lock issue on Github")
+			return err
+		}
+		recorder.Eventf(ghissue, corev1.EventTypeNormal, eventIssueLocked, "Locked issue #%d", issue.Number)
+	}
+	controllerutil.RemoveFinalizer(ghissue, finalizerName) // successful deletion of external resources -> remove our finalizer from the list
 	return nil
 }
 
 /**** HELPERS ****/
-func searchIssueByTitle(issues []*github.Issue, title string) (*github.Issue, error) {
+func searchIssueByTitle(issues []*tracker.Issue, title string, adoptable func(*tracker.Issue) bool) (*tracker.Issue, error) {
 	for _, issue := range issues {
 		// i is the index where we are, title is the element from titles slice for where we are
-		if title == *issue.Title {
+		if title == issue.Title && adoptable(issue) {
 			return issue, nil
 		}
 	}
 	return nil, fmt.Errorf("issue %s not found", title)
 }
 
-func getGithubClient() (*github.Client, context.Context) {
-	tkn := os.Getenv("TOKEN")
-	ctx1 := context.Background()
+func searchIssueByNumber(issues []*tracker.Issue, number int) *tracker.Issue {
+	for _, issue := range issues {
+		if issue.Number == number {
+			return issue
+		}
+	}
+	return nil
+}
+
+/*
+limiter (may be nil) tracks the quota of the client under key
+*/
+func getGithubClient(tkn string, endpoint GithubEndpoint, limiter *rateLimiter, key string) (*github.Client, error) {
+	httpClient, err := endpoint.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	httpClient = limiter.wrap(httpClient, key)
+	ctx1 := context.WithValue(context.Background(), oauth2.HTTPClient, httpClient)
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: tkn},
 	)
 	tc := oauth2.NewClient(ctx1, ts)
-	githubClient := github.NewClient(tc)
-	return githubClient, ctx1
+	return endpoint.newClient(tc)
 }
 
 func log404(logger logr.Logger) {
 	logger.Info("Returned status is 404 -> Request object Not Found (could have been deleted after reconcile request)")
 }
 
-func splitOwnerRepo(githubIssueRepo string) (owner string, repo string) {
-	// LeeJoeBarak/githubissue-operator
+/*
+splits owner/repo (e.g. LeeJoeBarak/githubissue-operator). Anything else, such as a Jira project key or a GitLab
+project in a subgroup, is a specError
+*/
+func splitOwnerRepo(githubIssueRepo string) (owner string, repo string, err error) {
 	split := strings.Split(githubIssueRepo, "/")
-	owner = split[0]
-	repo = split[1]
-	return owner, repo
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return "", "", invalidSpec("Repo %q is not owner/repo", githubIssueRepo)
+	}
+	return split[0], split[1], nil
+}
+
+func stateClosed(issue *tracker.Issue) bool {
+	return issue != nil && issue.State == tracker.StateClosed
+}
+
+func deletionComment(ghissue *g.GithubIssue) string {
+	if ghissue.Spec.DeletionComment != "" {
+		return ghissue.Spec.DeletionComment
+	}
+	return fmt.Sprintf("Closing: the GithubIssue object %s/%s managing this issue was deleted.", ghissue.Namespace, ghissue.Name)
+}
+
+func desiredState(ghissue *g.GithubIssue) string {
+	if ghissue.Spec.State == "closed" {
+		return "closed"
+	}
+	return "open"
 }
 
-func stateClosed(issue *github.Issue) bool {
-	return issue != nil && *issue.State == "closed"
+func isDescriptionEqual(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	return issue.Body == issueBody(ghissue)
 }
 
-func isDescriptionEqual(issue *github.Issue, ghissue *g.GithubIssue) bool {
-	return *issue.Body == ghissue.Spec.Desc
+/*
+how ghissue came to be bound to the existing issue
+*/
+func adoptionReason(issue *tracker.Issue, ghissue *g.GithubIssue) (reason, message string) {
+	switch {
+	case issue.Number == ghissue.Spec.IssueNumber:
+		return g.ReasonIssueNumber, fmt.Sprintf("Adopted the existing issue #%d named by spec.issueNumber", issue.Number)
+	case issueOwner(issue) == ownerKey(ghissue):
+		return g.ReasonOwnershipMarker, fmt.Sprintf("Bound to the existing issue #%d carrying this object's ownership marker", issue.Number)
+	case predatesOwnershipMarker(ghissue):
+		return g.ReasonTitleMatch, fmt.Sprintf("Adopted the existing issue #%d with the same title, the object was bound to it before the ownership marker existed", issue.Number)
+	}
+	return g.ReasonTitleMatch, fmt.Sprintf("Adopted the existing issue #%d with the same title", issue.Number)
+}
+
+func isTitleEqual(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	return issue.Title == ghissue.Spec.Title
+}
+
+/*
+log a failed github api call. The response body may contain hints in case of errors
+*/
+func logGithubError(logger logr.Logger, err error, resp *github.Response, msg string) {
+	if resp == nil {
+		logger.Error(err, msg)
+		return
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	logger.Error(err, msg, "Github api response code is", resp.StatusCode, "The response body is", string(body))
 }
 
 /**** UTILS ****/
@@ -0,0 +1,536 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+const (
+	timeout  = 10 * time.Second
+	interval = 100 * time.Millisecond
+)
+
+var _ = Describe("GithubIssue controller", func() {
+	ctx := context.Background()
+	var repo string
+
+	BeforeEach(func() {
+		/* every spec has a repository of its own, so specs do not see each other's issues */
+		repo = fmt.Sprintf("octo/repo-%d", time.Now().UnixNano())
+		fakeServer.addRepo(repo, "octocat")
+	})
+
+	newGithubIssue := func(title string, mutate ...func(spec *g.GithubIssueSpec)) *g.GithubIssue {
+		ghissue := &g.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githubissue-"},
+			Spec: g.GithubIssueSpec{
+				Title: title,
+				Repo:  repo,
+				Desc:  "description of " + title,
+			},
+		}
+		for _, m := range mutate {
+			m(&ghissue.Spec)
+		}
+		Expect(k8sClient.Create(ctx, ghissue)).To(Succeed())
+		return ghissue
+	}
+
+	fetch := func(ghissue *g.GithubIssue) *g.GithubIssue {
+		fetched := &g.GithubIssue{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ghissue.Namespace, Name: ghissue.Name}, fetched)).To(Succeed())
+		return fetched
+	}
+
+	condition := func(ghissue *g.GithubIssue, conditionType string) func() string {
+		return func() string {
+			c := meta.FindStatusCondition(fetch(ghissue).Status.Conditions, conditionType)
+			if c == nil {
+				return ""
+			}
+			return string(c.Status) + "/" + c.Reason
+		}
+	}
+
+	issueNumber := func(ghissue *g.GithubIssue) func() int {
+		return func() int {
+			return fetch(ghissue).Status.Number
+		}
+	}
+
+	update := func(ghissue *g.GithubIssue, mutate func(spec *g.GithubIssueSpec)) {
+		Eventually(func() error {
+			fetched := fetch(ghissue)
+			mutate(&fetched.Spec)
+			return k8sClient.Update(ctx, fetched)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	gone := func(ghissue *g.GithubIssue) func() bool {
+		return func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: ghissue.Namespace, Name: ghissue.Name}, &g.GithubIssue{})
+			return errors.IsNotFound(err)
+		}
+	}
+
+	Context("creating an issue", func() {
+		It("creates the issue with the ownership marker and records it in the status", func() {
+			ghissue := newGithubIssue("create", func(spec *g.GithubIssueSpec) {
+				spec.Labels = []string{"bug"}
+				spec.Assignees = []string{"octocat"}
+			})
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			issue := fakeServer.issue(repo, 1)
+			Expect(issue.Title).To(Equal("create"))
+			Expect(issue.Body).To(Equal(issueBody(fetch(ghissue))))
+			Expect(issue.Body).To(HaveSuffix(fmt.Sprintf(ownershipMarkerFormat, ghissue.Namespace+"/"+ghissue.Name)))
+			Expect(issue.labelNames()).To(ConsistOf("bug"))
+			Expect(issue.assigneeLogins()).To(ConsistOf("octocat"))
+
+			status := fetch(ghissue).Status
+			Expect(status.State).To(Equal("open"))
+			Expect(status.NodeID).To(Equal(issue.NodeID))
+			Expect(status.HTMLURL).To(Equal(issue.HTMLURL))
+			Expect(meta.IsStatusConditionFalse(status.Conditions, g.ConditionAdopted)).To(BeTrue())
+			Expect(fakeServer.issueCount(repo)).To(Equal(1))
+		})
+
+		It("records assignees github refuses instead of failing", func() {
+			ghissue := newGithubIssue("rejected assignee", func(spec *g.GithubIssueSpec) {
+				spec.Assignees = []string{"octocat", "stranger"}
+			})
+			Eventually(func() []string { return fetch(ghissue).Status.RejectedAssignees }, timeout, interval).Should(ConsistOf("stranger"))
+			Expect(fakeServer.issue(repo, 1).assigneeLogins()).To(ConsistOf("octocat"))
+
+			/* a later reconcile does not ask github about the same assignees again */
+			checks := fakeServer.requestCount("GET", "/assignees/stranger")
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.Desc = "trigger a reconcile" })
+			Eventually(func() string { return bodyWithoutMarker(fakeServer.issue(repo, 1).Body) }, timeout, interval).Should(Equal("trigger a reconcile"))
+			Expect(fakeServer.requestCount("GET", "/assignees/stranger")).To(Equal(checks))
+		})
+	})
+
+	Context("updating an issue", func() {
+		It("applies edits of the spec to the issue", func() {
+			ghissue := newGithubIssue("before")
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			update(ghissue, func(spec *g.GithubIssueSpec) {
+				spec.Title = "after"
+				spec.Desc = "new description"
+				spec.Labels = []string{"enhancement", "help wanted"}
+				spec.Assignees = []string{"octocat"}
+			})
+			Eventually(func() string { return fakeServer.issue(repo, 1).Title }, timeout, interval).Should(Equal("after"))
+			Eventually(func() []string { return fakeServer.issue(repo, 1).labelNames() }, timeout, interval).Should(ConsistOf("enhancement", "help wanted"))
+			Eventually(func() []string { return fakeServer.issue(repo, 1).assigneeLogins() }, timeout, interval).Should(ConsistOf("octocat"))
+			Expect(bodyWithoutMarker(fakeServer.issue(repo, 1).Body)).To(Equal("new description"))
+			Expect(fakeServer.issueCount(repo)).To(Equal(1))
+		})
+
+		It("closes and reopens the issue with spec.state", func() {
+			ghissue := newGithubIssue("state")
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+
+			update(ghissue, func(spec *g.GithubIssueSpec) {
+				spec.State = "closed"
+				spec.StateReason = "not_planned"
+			})
+			Eventually(func() string { return fakeServer.issue(repo, 1).State }, timeout, interval).Should(Equal("closed"))
+			Expect(fakeServer.issue(repo, 1).StateReason).To(Equal("not_planned"))
+			Eventually(func() string { return fetch(ghissue).Status.State }, timeout, interval).Should(Equal("closed"))
+
+			update(ghissue, func(spec *g.GithubIssueSpec) {
+				spec.State = "open"
+				spec.StateReason = ""
+			})
+			Eventually(func() string { return fakeServer.issue(repo, 1).State }, timeout, interval).Should(Equal("open"))
+		})
+	})
+
+	Context("deleting the object", func() {
+		It("closes the issue and removes the finalizer", func() {
+			ghissue := newGithubIssue("delete")
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+			Expect(fetch(ghissue).Finalizers).To(ContainElement(finalizerName))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("closed"))
+			Expect(fakeServer.issue(repo, 1).Locked).To(BeFalse())
+		})
+
+		It("posts the deletion comment before closing with CommentAndClose", func() {
+			ghissue := newGithubIssue("comment and close", func(spec *g.GithubIssueSpec) {
+				spec.DeletionPolicy = g.DeletionPolicyCommentAndClose
+				spec.DeletionComment = "closed by the operator"
+			})
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).Comments).To(ConsistOf("closed by the operator"))
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("closed"))
+		})
+
+		It("does not post the deletion comment again when closing is retried", func() {
+			ghissue := newGithubIssue("comment once", func(spec *g.GithubIssueSpec) {
+				spec.DeletionPolicy = g.DeletionPolicyCommentAndClose
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			fakeServer.failNext("PATCH", repo+"/issues/1", http.StatusInternalServerError, 2)
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("closed"))
+			Expect(fakeServer.issue(repo, 1).Comments).To(HaveLen(1))
+		})
+
+		It("locks the issue with Lock", func() {
+			ghissue := newGithubIssue("lock", func(spec *g.GithubIssueSpec) {
+				spec.DeletionPolicy = g.DeletionPolicyLock
+			})
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("closed"))
+			Expect(fakeServer.issue(repo, 1).Locked).To(BeTrue())
+		})
+
+		It("leaves the issue untouched with Retain", func() {
+			ghissue := newGithubIssue("retain", func(spec *g.GithubIssueSpec) {
+				spec.DeletionPolicy = g.DeletionPolicyRetain
+			})
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("open"))
+		})
+
+		It("removes the finalizer when the credentials Secret is gone, as it is when the namespace is deleted", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "team-credentials-"},
+				StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			ghissue := newGithubIssue("secret gone", func(spec *g.GithubIssueSpec) {
+				spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.issue(repo, 1).State).To(Equal("open"))
+		})
+	})
+
+	Context("adopting an existing issue", func() {
+		It("binds to the issue named by spec.issueNumber", func() {
+			number := fakeServer.addIssue(repo, "filed by hand", "written by a human")
+			ghissue := newGithubIssue("named by number", func(spec *g.GithubIssueSpec) {
+				spec.IssueNumber = number
+			})
+			Eventually(condition(ghissue, g.ConditionAdopted), timeout, interval).Should(Equal("True/" + g.ReasonIssueNumber))
+			Eventually(func() string { return fakeServer.issue(repo, number).Title }, timeout, interval).Should(Equal("named by number"))
+			Expect(fakeServer.issueCount(repo)).To(Equal(1))
+		})
+
+		It("pages through the repository to adopt an unowned issue with the same title under TitleMatch", func() {
+			number := fakeServer.addIssue(repo, "old issue", "")
+			for i := 0; i < 12; i++ {
+				fakeServer.addIssue(repo, fmt.Sprintf("newer issue %d", i), "")
+			}
+			ghissue := newGithubIssue("old issue", func(spec *g.GithubIssueSpec) {
+				spec.AdoptionPolicy = g.AdoptionPolicyTitleMatch
+			})
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(number))
+			Eventually(condition(ghissue, g.ConditionAdopted), timeout, interval).Should(Equal("True/" + g.ReasonTitleMatch))
+			Expect(fakeServer.requestCount("GET", repo+"/issues")).To(BeNumerically(">=", 3)) // 13 issues, 5 per page
+			Expect(fakeServer.issueCount(repo)).To(Equal(13))
+		})
+
+		It("creates its own issue instead of adopting one with the same title under Never", func() {
+			fakeServer.addIssue(repo, "same title", "")
+			ghissue := newGithubIssue("same title")
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(2))
+			Expect(fakeServer.issue(repo, 1).Body).To(BeEmpty())
+		})
+
+		It("adopts the issue of an object that predates the ownership marker once and stamps the marker", func() {
+			number := fakeServer.addIssue(repo, "legacy", "description of legacy")
+			ghissue := newGithubIssue("legacy", func(spec *g.GithubIssueSpec) {
+				spec.Repo = "octo/does-not-exist"
+			})
+			Eventually(condition(ghissue, g.ConditionSynced), timeout, interval).Should(Equal("False/" + g.ReasonLookupFailed))
+			/* the status an older operator left behind: a state, but no number */
+			Eventually(func() error {
+				fetched := fetch(ghissue)
+				fetched.Status.State = "open"
+				fetched.Status.Number = 0
+				return k8sClient.Status().Update(ctx, fetched)
+			}, timeout, interval).Should(Succeed())
+
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.Repo = repo })
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(number))
+			Eventually(func() string { return fakeServer.issue(repo, number).Body }, timeout, interval).Should(
+				HaveSuffix(fmt.Sprintf(ownershipMarkerFormat, ghissue.Namespace+"/"+ghissue.Name)))
+			Expect(fakeServer.issueCount(repo)).To(Equal(1))
+		})
+
+		It("refuses an issue owned by another object", func() {
+			number := fakeServer.addIssue(repo, "owned", fmt.Sprintf(ownershipMarkerFormat, "other-namespace/other-object"))
+			ghissue := newGithubIssue("taking over", func(spec *g.GithubIssueSpec) {
+				spec.IssueNumber = number
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonNotOwned))
+			Consistently(func() string { return fakeServer.issue(repo, number).Title }, time.Second, interval).Should(Equal("owned"))
+		})
+	})
+
+	Context("when the issue is edited on github", func() {
+		It("does not report a description ending in newlines or rejected assignees as drift", func() {
+			ghissue := newGithubIssue("block scalar", func(spec *g.GithubIssueSpec) {
+				spec.Desc = "line one\nline two\n"
+				spec.Assignees = []string{"octocat", "stranger"}
+				spec.DriftPolicy = g.DriftPolicyReport
+			})
+			Eventually(func() []string { return fetch(ghissue).Status.RejectedAssignees }, timeout, interval).Should(ConsistOf("stranger"))
+
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.Title = "block scalar, edited" })
+			Eventually(func() string { return fakeServer.issue(repo, 1).Title }, timeout, interval).Should(Equal("block scalar, edited"))
+			Eventually(condition(ghissue, g.ConditionDrifted), timeout, interval).Should(Equal("False/" + g.ReasonNoDrift))
+			Expect(bodyWithoutMarker(fakeServer.issue(repo, 1).Body)).To(Equal("line one\nline two\n"))
+		})
+
+		It("reports the edit of an older issue of a busy repository with driftPolicy Report", func() {
+			number := fakeServer.addIssue(repo, "older issue", "")
+			for i := 0; i < 7; i++ {
+				fakeServer.addIssue(repo, fmt.Sprintf("newer issue %d", i), "")
+			}
+			ghissue := newGithubIssue("older issue", func(spec *g.GithubIssueSpec) {
+				spec.AdoptionPolicy = g.AdoptionPolicyTitleMatch
+				spec.DriftPolicy = g.DriftPolicyReport
+			})
+			Eventually(condition(ghissue, g.ConditionDrifted), timeout, interval).Should(Equal("False/" + g.ReasonNoDrift))
+
+			fakeServer.editIssue(repo, number, func(issue *fakeIssue) { issue.Title = "edited by hand" })
+			/* the issue is not on the first page of the repository, the incremental listing still has to see it */
+			time.Sleep(time.Second) // the cache interval
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.Desc = "trigger a reconcile" })
+			Eventually(condition(ghissue, g.ConditionDrifted), timeout, interval).Should(Equal("True/" + g.ReasonDriftDetected))
+			Expect(fetch(ghissue).Status.Drift).To(ContainElement(g.FieldDrift{Field: "title", Spec: "older issue", GitHub: "edited by hand"}))
+			Expect(fakeServer.issue(repo, number).Title).To(Equal("edited by hand"))
+		})
+	})
+
+	Context("with syncDirection Bidirectional", func() {
+		touch := func(ghissue *g.GithubIssue, value string) {
+			Eventually(func() error {
+				fetched := fetch(ghissue)
+				fetched.Annotations = map[string]string{"test/trigger": value}
+				return k8sClient.Update(ctx, fetched)
+			}, timeout, interval).Should(Succeed())
+		}
+
+		It("does not copy its own write back into the spec, but copies later edits made on github", func() {
+			ghissue := newGithubIssue("two-way", func(spec *g.GithubIssueSpec) {
+				spec.SyncDirection = g.SyncDirectionBidirectional
+				spec.LabelPolicy = g.LabelPolicyOwnedOnly
+				spec.Labels = []string{"bug"}
+				spec.Assignees = []string{"octocat", "stranger"}
+				spec.Desc = "ends in a newline\n"
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			/* a label added by a human is not part of the spec under OwnedOnly */
+			fakeServer.editIssue(repo, 1, func(issue *fakeIssue) { issue.Labels = append(issue.Labels, fakeLabel{Name: "triage"}) })
+			time.Sleep(time.Second) // the cache interval
+			touch(ghissue, "1")
+			Consistently(func() int64 { return fetch(ghissue).Generation }, 2*time.Second, interval).Should(Equal(fetch(ghissue).Generation))
+			spec := fetch(ghissue).Spec
+			Expect(spec.Labels).To(ConsistOf("bug"))
+			Expect(spec.Assignees).To(ConsistOf("octocat", "stranger"))
+
+			fakeServer.editIssue(repo, 1, func(issue *fakeIssue) { issue.Title = "two-way, edited on github" })
+			time.Sleep(time.Second) // the cache interval
+			touch(ghissue, "2")
+			Eventually(func() string { return fetch(ghissue).Spec.Title }, timeout, interval).Should(Equal("two-way, edited on github"))
+			spec = fetch(ghissue).Spec
+			Expect(spec.Labels).To(ConsistOf("bug"))
+			Expect(spec.Assignees).To(ConsistOf("octocat", "stranger"))
+			Expect(spec.Desc).To(Equal("ends in a newline\n"))
+			Expect(fakeServer.issue(repo, 1).labelNames()).To(ConsistOf("bug", "triage"))
+		})
+	})
+
+	Context("when the issue is deleted on github", func() {
+		It("evicts it from the cache and files a new issue", func() {
+			ghissue := newGithubIssue("deleted")
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+
+			fakeServer.deleteIssue(repo, 1)
+			/* incremental listings do not report deleted issues, only the failed write does */
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.Title = "deleted and edited" })
+			Eventually(issueNumber(ghissue), timeout, interval).Should(Equal(2))
+			Expect(fakeServer.issue(repo, 2).Title).To(Equal("deleted and edited"))
+		})
+	})
+
+	Context("choosing the credentials", func() {
+		It("does not send the operator-wide credentials to another endpoint", func() {
+			ghissue := newGithubIssue("other endpoint", func(spec *g.GithubIssueSpec) {
+				spec.BaseURL = "https://github.example.com/api/v3/"
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonCredentialsRefRequired))
+
+			/* the operator-wide endpoint spelled out is fine */
+			update(ghissue, func(spec *g.GithubIssueSpec) { spec.BaseURL = fakeServer.endpoint().BaseURL })
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+		})
+	})
+
+	Context("with a spec the provider can not carry out", func() {
+		It("rejects deletionPolicy Lock on Gitea without retrying", func() {
+			ghissue := newGithubIssue("locked on gitea", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderGitea
+				spec.BaseURL = "https://gitea.example.com/api/v1/"
+				spec.DeletionPolicy = g.DeletionPolicyLock
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+			Expect(condition(ghissue, g.ConditionSynced)()).To(Equal("False/" + g.ReasonInvalidSpec))
+		})
+
+		It("rejects Gitea without baseURL, and lets such an object be deleted", func() {
+			ghissue := newGithubIssue("gitea without endpoint", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderGitea
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+		})
+
+		It("rejects a Github App as credentials of Gitea without retrying", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "app-credentials-"},
+				StringData: map[string]string{appIDKey: "42", privateKeyKey: "not parsed before the provider is checked"},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			ghissue := newGithubIssue("gitea with an app", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderGitea
+				spec.BaseURL = "https://gitea.example.com/api/v1/"
+				spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+		})
+
+		It("rejects Jira without the site as baseURL, and lets such an object be deleted", func() {
+			ghissue := newGithubIssue("jira without site", func(spec *g.GithubIssueSpec) {
+				spec.Repo = "PROJ"
+				spec.Provider = g.ProviderJira
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghissue))).To(Succeed())
+			Eventually(gone(ghissue), timeout, interval).Should(BeTrue())
+		})
+
+		It("rejects milestoneRef and stateReason on GitLab without retrying", func() {
+			withMilestone := newGithubIssue("milestone on gitlab", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderGitLab
+				spec.MilestoneRef = &g.LocalObjectReference{Name: "sprint"}
+			})
+			Eventually(condition(withMilestone, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+			Expect(condition(withMilestone, g.ConditionMilestoneResolved)()).To(BeEmpty())
+
+			withReason := newGithubIssue("state reason on gitlab", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderGitLab
+				spec.State = "closed"
+				spec.StateReason = "not_planned"
+			})
+			Eventually(condition(withReason, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+
+			Expect(k8sClient.Delete(ctx, fetch(withMilestone))).To(Succeed())
+			Eventually(gone(withMilestone), timeout, interval).Should(BeTrue())
+		})
+
+		It("rejects a repo the provider does not take without retrying", func() {
+			ghissue := newGithubIssue("project key on github", func(spec *g.GithubIssueSpec) {
+				spec.Repo = "PROJ"
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+
+			other := newGithubIssue("owner/repo on jira", func(spec *g.GithubIssueSpec) {
+				spec.Provider = g.ProviderJira
+			})
+			Eventually(condition(other, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+		})
+
+		It("rejects deletionPolicy Lock on Jira without retrying", func() {
+			ghissue := newGithubIssue("locked on jira", func(spec *g.GithubIssueSpec) {
+				spec.Repo = "PROJ"
+				spec.Provider = g.ProviderJira
+				spec.BaseURL = "https://example.atlassian.net"
+				spec.DeletionPolicy = g.DeletionPolicyLock
+			})
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("False/" + g.ReasonInvalidSpec))
+		})
+	})
+
+	Context("when github fails", func() {
+		It("retries a failed creation without filing duplicates", func() {
+			fakeServer.failNext("POST", repo+"/issues", http.StatusInternalServerError, 3)
+			ghissue := newGithubIssue("flaky")
+			Eventually(condition(ghissue, g.ConditionReady), timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+			Expect(fakeServer.requestCount("POST", repo+"/issues")).To(Equal(4))
+			Expect(fakeServer.issueCount(repo)).To(Equal(1))
+		})
+
+		It("records the failed lookup for a repository that does not exist", func() {
+			ghissue := newGithubIssue("missing repo", func(spec *g.GithubIssueSpec) {
+				spec.Repo = "octo/does-not-exist"
+			})
+			Eventually(condition(ghissue, g.ConditionSynced), timeout, interval).Should(Equal("False/" + g.ReasonLookupFailed))
+			/* 404 is an answer, github is reachable */
+			Expect(condition(ghissue, g.ConditionGitHubReachable)()).To(Equal("True/" + g.ReasonConnected))
+		})
+
+		It("reports github server errors in the GitHubReachable condition", func() {
+			fakeServer.failNext("GET", repo+"/issues", http.StatusBadGateway, 1000)
+			ghissue := newGithubIssue("server error")
+			Eventually(condition(ghissue, g.ConditionGitHubReachable), timeout, interval).Should(Equal("False/" + g.ReasonGitHubServerError))
+			Expect(condition(ghissue, g.ConditionSynced)()).To(Equal("False/" + g.ReasonLookupFailed))
+
+			fakeServer.clearFailures()
+			Eventually(condition(ghissue, g.ConditionReady), 3*timeout, interval).Should(Equal("True/" + g.ReasonIssueSynced))
+		})
+
+		It("pauses while the quota of the credentials is low and resumes after the reset", func() {
+			fakeServer.setRateLimit(10, 3*time.Second)
+			resetAt := time.Now().Add(3 * time.Second)
+			ghissue := newGithubIssue("rate limited")
+			Eventually(issueNumber(ghissue), 3*timeout, interval).Should(Equal(1))
+			Expect(fakeServer.issue(repo, 1).CreatedAt).To(BeTemporally(">=", resetAt.Add(-time.Second)))
+		})
+	})
+})
@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// GithubIssueCommentReconciler reconciles a GithubIssueComment object
+type GithubIssueCommentReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// DefaultCredentialsSecret and DefaultEndpoint are the same as GithubIssueReconciler's: a comment talks to github
+	// the way the GithubIssue it references does
+	DefaultCredentialsSecret types.NamespacedName
+	DefaultEndpoint          GithubEndpoint
+	RateLimitThreshold       int
+	// Clients is the same as GithubIssueReconciler's
+	Clients *GithubClients
+}
+
+const (
+	commentFinalizerName = "training.redhat.com/comment-finalizer"
+	// issueRefNameField indexes GithubIssueComment objects by the name of the GithubIssue they comment on
+	issueRefNameField = ".spec.issueRef.name"
+)
+
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissuecomments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissuecomments/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissuecomments/finalizers,verbs=update
+
+func (r *GithubIssueCommentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("githubissuecomment_name", req.NamespacedName)
+
+	comment := g.GithubIssueComment{}
+	err := r.Client.Get(ctx, req.NamespacedName, &comment)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log404(logger)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Error reading the object -> Requeue the request.")
+		return ctrl.Result{}, err
+	}
+	deleting := !comment.ObjectMeta.DeletionTimestamp.IsZero()
+	if deleting && !controllerutil.ContainsFinalizer(&comment, commentFinalizerName) {
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&comment, commentFinalizerName) {
+		controllerutil.AddFinalizer(&comment, commentFinalizerName)
+		err = r.Update(ctx, &comment)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	/* the comment lives on the github issue of the referenced GithubIssue, and talks to github with its credentials */
+	ghissue := g.GithubIssue{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: comment.Namespace, Name: comment.Spec.IssueRef.Name}, &ghissue)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if deleting {
+			/* the GithubIssue is gone, and its credentials with it -> the comment can't be touched anymore */
+			return ctrl.Result{}, r.removeCommentFinalizer(ctx, &comment, logger)
+		}
+		setStatusCondition(&comment.Status.Conditions, comment.Generation, g.ConditionReady, metav1.ConditionFalse, g.ReasonIssueNotFound,
+			fmt.Sprintf("GithubIssue %s not found", comment.Spec.IssueRef.Name))
+		return ctrl.Result{}, r.updateCommentStatus(ctx, &comment) // the GithubIssue watch requeues us once it exists
+	}
+	if provider := ghissue.Spec.Provider; provider != "" && provider != g.ProviderGitHub {
+		if deleting {
+			return ctrl.Result{}, r.removeCommentFinalizer(ctx, &comment, logger)
+		}
+		setStatusCondition(&comment.Status.Conditions, comment.Generation, g.ConditionReady, metav1.ConditionFalse, g.ReasonProviderNotSupported,
+			fmt.Sprintf("GithubIssue %s is on %s, comments are only supported on GitHub", ghissue.Name, provider))
+		return ctrl.Result{}, r.updateCommentStatus(ctx, &comment)
+	}
+	githubClient, err := r.Clients.clientFor(ctx, r.Client, issueTarget(&ghissue))
+	if err != nil && deleting && isUnrecoverableOnDeletion(err, issueTarget(&ghissue)) {
+		r.Recorder.Eventf(&comment, corev1.EventTypeWarning, eventCommentAbandoned,
+			"Removed the finalizer without handling comment %d on issue #%d: %v", comment.Status.CommentID, comment.Status.IssueNumber, err)
+		return ctrl.Result{}, r.removeCommentFinalizer(ctx, &comment, logger)
+	}
+	if err != nil {
+		logger.Error(err, "While trying to read the Github credentials")
+		reason, retry := credentialsFailure(err)
+		setStatusCondition(&comment.Status.Conditions, comment.Generation, g.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+		r.Recorder.Event(&comment, corev1.EventTypeWarning, eventCredentialsError, err.Error())
+		_ = r.updateCommentStatus(ctx, &comment)
+		return ctrl.Result{}, retry
+	}
+	ctx1 := context.Background()
+
+	if deleting {
+		err = r.handleCommentDeletion(githubClient, ctx1, &comment, logger)
+		if err != nil {
+			logger.Error(err, "While trying to delete comment on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &comment, &comment.Status.Conditions, g.ReasonDeletionFailed, err)
+		}
+		return ctrl.Result{}, r.removeCommentFinalizer(ctx, &comment, logger)
+	}
+
+	if ghissue.Status.Number == 0 {
+		setStatusCondition(&comment.Status.Conditions, comment.Generation, g.ConditionReady, metav1.ConditionFalse, g.ReasonIssueNotReady,
+			fmt.Sprintf("GithubIssue %s has no github issue yet", ghissue.Name))
+		return ctrl.Result{}, r.updateCommentStatus(ctx, &comment)
+	}
+	owner, repo, err := splitOwnerRepo(ghissue.Spec.Repo)
+	if err != nil {
+		return readyFailed(ctx, r.Client, r.Recorder, r.Log, &comment, &comment.Status.Conditions, g.ReasonInvalidSpec, err)
+	}
+	posted := comment.Status.CommentID != 0 && comment.Status.Repo == ghissue.Spec.Repo && comment.Status.IssueNumber == ghissue.Status.Number
+	if posted && comment.Status.AppliedBodyHash != bodyHash(comment.Spec.Body) {
+		/* the body changed since it was last written, possibly while an earlier reconcile failed */
+		issueComment, err := editCommentOnGithub(githubClient, ctx1, owner, repo, comment.Status.CommentID, comment.Spec.Body, logger)
+		if err != nil {
+			logger.Error(err, "While trying to update comment on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &comment, &comment.Status.Conditions, g.ReasonUpdateFailed, err)
+		}
+		if issueComment == nil {
+			posted = false // deleted on github -> post it again
+		} else {
+			comment.Status.AppliedBodyHash = bodyHash(comment.Spec.Body)
+			r.Recorder.Eventf(&comment, corev1.EventTypeNormal, eventCommentUpdated, "Updated comment %d on issue #%d", comment.Status.CommentID, comment.Status.IssueNumber)
+		}
+	}
+	if !posted {
+		issueComment, err := createCommentOnGithub(githubClient, ctx1, owner, repo, ghissue.Status.Number, comment.Spec.Body, logger)
+		if err != nil {
+			logger.Error(err, "While trying to create comment on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &comment, &comment.Status.Conditions, g.ReasonCreateFailed, err)
+		}
+		comment.Status.CommentID = issueComment.GetID()
+		comment.Status.NodeID = issueComment.GetNodeID()
+		comment.Status.HTMLURL = issueComment.GetHTMLURL()
+		comment.Status.Repo = ghissue.Spec.Repo
+		comment.Status.IssueNumber = ghissue.Status.Number
+		comment.Status.AppliedBodyHash = bodyHash(comment.Spec.Body)
+		r.Recorder.Eventf(&comment, corev1.EventTypeNormal, eventCommentPosted, "Posted comment on issue #%d %s", ghissue.Status.Number, issueComment.GetHTMLURL())
+	}
+	setStatusCondition(&comment.Status.Conditions, comment.Generation, g.ConditionReady, metav1.ConditionTrue, g.ReasonCommentPosted,
+		fmt.Sprintf("Comment is posted on issue #%d", comment.Status.IssueNumber))
+	return ctrl.Result{}, r.updateCommentStatus(ctx, &comment)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GithubIssueCommentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clients == nil {
+		r.Clients = NewGithubClients(r.DefaultCredentialsSecret, r.DefaultEndpoint, r.RateLimitThreshold)
+	}
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &g.GithubIssueComment{}, issueRefNameField, func(obj client.Object) []string {
+		return []string{obj.(*g.GithubIssueComment).Spec.IssueRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&g.GithubIssueComment{}).
+		Watches(&source.Kind{Type: &g.GithubIssue{}}, handler.EnqueueRequestsFromMapFunc(r.commentsForGithubIssue)).
+		Complete(r)
+}
+
+/*
+maps a GithubIssue to the GithubIssueComments on it, so they are posted as soon as the issue exists
+*/
+func (r *GithubIssueCommentReconciler) commentsForGithubIssue(ghissue client.Object) []reconcile.Request {
+	comments := g.GithubIssueCommentList{}
+	err := r.List(context.Background(), &comments, client.InNamespace(ghissue.GetNamespace()), client.MatchingFields{issueRefNameField: ghissue.GetName()})
+	if err != nil {
+		r.Log.Error(err, "Listing the GithubIssueComments of a GithubIssue failed", "githubissue", ghissue.GetNamespace()+"/"+ghissue.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(comments.Items))
+	for _, comment := range comments.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: comment.Namespace, Name: comment.Name}})
+	}
+	return requests
+}
+
+/*
+the comment status keeps only a hash of the body it wrote, not the body itself
+*/
+func bodyHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *GithubIssueCommentReconciler) updateCommentStatus(ctx context.Context, comment *g.GithubIssueComment) error {
+	comment.Status.ObservedGeneration = comment.Generation
+	err := r.Status().Update(ctx, comment)
+	if err != nil {
+		r.Log.Error(err, "((GithubIssueCommentReconciler)r).Status().Update() failed ")
+		return err
+	}
+	return nil
+}
+
+func (r *GithubIssueCommentReconciler) removeCommentFinalizer(ctx context.Context, comment *g.GithubIssueComment, logger logr.Logger) error {
+	controllerutil.RemoveFinalizer(comment, commentFinalizerName)
+	err := r.Update(ctx, comment)
+	if err != nil {
+		logger.Error(err, "r.Update() failed")
+		return err
+	}
+	return nil
+}
+
+/*
+delete, minimize or retain the github comment according to spec.deletionPolicy
+*/
+func (r *GithubIssueCommentReconciler) handleCommentDeletion(githubClient *github.Client, ctx context.Context, comment *g.GithubIssueComment, logger logr.Logger) error {
+	if comment.Status.CommentID == 0 || comment.Spec.DeletionPolicy == g.CommentDeletionPolicyRetain {
+		return nil
+	}
+	if comment.Spec.DeletionPolicy == g.CommentDeletionPolicyMinimize {
+		err := minimizeCommentOnGithub(githubClient, ctx, comment.Status.NodeID, logger)
+		if err != nil {
+			return err
+		}
+		r.Recorder.Eventf(comment, corev1.EventTypeNormal, eventCommentMinimized, "Minimized comment %d on issue #%d", comment.Status.CommentID, comment.Status.IssueNumber)
+		return nil
+	}
+	owner, repo, err := splitOwnerRepo(comment.Status.Repo)
+	if err != nil {
+		return err
+	}
+	err = deleteCommentOnGithub(githubClient, ctx, owner, repo, comment.Status.CommentID, logger)
+	if err != nil {
+		return err
+	}
+	r.Recorder.Eventf(comment, corev1.EventTypeNormal, eventCommentDeleted, "Deleted comment %d on issue #%d", comment.Status.CommentID, comment.Status.IssueNumber)
+	return nil
+}
+
+func createCommentOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, number int, body string, logger logr.Logger) (*github.IssueComment, error) {
+	issueComment, resp, err := githubClient.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
+		logGithubError(logger.WithName("createCommentOnGithub()"), err, resp, "Creation of github comment failed")
+		return nil, err
+	}
+	return issueComment, nil
+}
+
+/*
+returns nil comment (and nil error) if the comment does not exist anymore
+*/
+func editCommentOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, commentID int64, body string, logger logr.Logger) (*github.IssueComment, error) {
+	issueComment, resp, err := githubClient.Issues.EditComment(ctx, owner, repo, commentID, &github.IssueComment{Body: github.String(body)})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		logGithubError(logger.WithName("editCommentOnGithub()"), err, resp, "Updating github comment failed")
+		return nil, err
+	}
+	return issueComment, nil
+}
+
+func deleteCommentOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, commentID int64, logger logr.Logger) error {
+	resp, err := githubClient.Issues.DeleteComment(ctx, owner, repo, commentID)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil // already gone
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusNoContent) {
+		logGithubError(logger.WithName("deleteCommentOnGithub()"), err, resp, "Deleting github comment failed")
+		return err
+	}
+	return nil
+}
+
+// graphqlRequest is the body of a github GraphQL API call. Minimizing a comment is not part of the REST API
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+const minimizeCommentMutation = `mutation($id: ID!) {
+  minimizeComment(input: {subjectId: $id, classifier: OUTDATED}) { minimizedComment { isMinimized } }
+}`
+
+func minimizeCommentOnGithub(githubClient *github.Client, ctx context.Context, nodeID string, logger logr.Logger) error {
+	req, err := githubClient.NewRequest("POST", graphqlURL(githubClient), &graphqlRequest{
+		Query:     minimizeCommentMutation,
+		Variables: map[string]interface{}{"id": nodeID},
+	})
+	if err != nil {
+		return err
+	}
+	result := graphqlResponse{}
+	resp, err := githubClient.Do(ctx, req, &result)
+	if err == nil && len(result.Errors) > 0 {
+		err = fmt.Errorf("minimizing github comment %s: %s", nodeID, result.Errors[0].Message)
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		logGithubError(logger.WithName("minimizeCommentOnGithub()"), err, resp, "Minimizing github comment failed")
+		return err
+	}
+	return nil
+}
+
+/*
+the GraphQL endpoint is https://api.github.com/graphql on github.com, but <host>/api/graphql on Github Enterprise Server
+(whose REST base URL is <host>/api/v3/)
+*/
+func graphqlURL(githubClient *github.Client) string {
+	if !strings.HasSuffix(githubClient.BaseURL.Path, "/api/v3/") {
+		return "graphql"
+	}
+	u := *githubClient.BaseURL
+	u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+	return u.String()
+}
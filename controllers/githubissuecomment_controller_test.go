@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+var _ = Describe("GithubIssueComment controller", func() {
+	ctx := context.Background()
+	var repo string
+
+	BeforeEach(func() {
+		repo = fmt.Sprintf("octo/comments-%d", time.Now().UnixNano())
+		fakeServer.addRepo(repo)
+	})
+
+	newGithubIssue := func(title string, mutate ...func(spec *g.GithubIssueSpec)) *g.GithubIssue {
+		ghissue := &g.GithubIssue{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githubissue-"},
+			Spec:       g.GithubIssueSpec{Title: title, Repo: repo, Desc: "description of " + title},
+		}
+		for _, m := range mutate {
+			m(&ghissue.Spec)
+		}
+		Expect(k8sClient.Create(ctx, ghissue)).To(Succeed())
+		return ghissue
+	}
+
+	newComment := func(issueName, body string, mutate ...func(spec *g.GithubIssueCommentSpec)) *g.GithubIssueComment {
+		comment := &g.GithubIssueComment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githubissuecomment-"},
+			Spec:       g.GithubIssueCommentSpec{IssueRef: g.LocalObjectReference{Name: issueName}, Body: body},
+		}
+		for _, m := range mutate {
+			m(&comment.Spec)
+		}
+		Expect(k8sClient.Create(ctx, comment)).To(Succeed())
+		return comment
+	}
+
+	fetch := func(comment *g.GithubIssueComment) *g.GithubIssueComment {
+		fetched := &g.GithubIssueComment{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: comment.Namespace, Name: comment.Name}, fetched)).To(Succeed())
+		return fetched
+	}
+
+	ready := func(comment *g.GithubIssueComment) func() string {
+		return func() string {
+			c := meta.FindStatusCondition(fetch(comment).Status.Conditions, g.ConditionReady)
+			if c == nil {
+				return ""
+			}
+			return string(c.Status) + "/" + c.Reason
+		}
+	}
+
+	commentID := func(comment *g.GithubIssueComment) func() int64 {
+		return func() int64 {
+			return fetch(comment).Status.CommentID
+		}
+	}
+
+	update := func(comment *g.GithubIssueComment, body string) {
+		Eventually(func() error {
+			fetched := fetch(comment)
+			fetched.Spec.Body = body
+			return k8sClient.Update(ctx, fetched)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	gone := func(comment *g.GithubIssueComment) func() bool {
+		return func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: comment.Namespace, Name: comment.Name}, &g.GithubIssueComment{})
+			return errors.IsNotFound(err)
+		}
+	}
+
+	githubBody := func(id func() int64) func() string {
+		return func() string {
+			posted := fakeServer.comment(repo, id())
+			if posted == nil {
+				return ""
+			}
+			return posted.Body
+		}
+	}
+
+	Context("posting a comment", func() {
+		It("posts the comment on the issue and records it in the status", func() {
+			ghissue := newGithubIssue("commented")
+			comment := newComment(ghissue.Name, "first!")
+			Eventually(ready(comment), timeout, interval).Should(Equal("True/" + g.ReasonCommentPosted))
+
+			Expect(fakeServer.issue(repo, 1).Comments).To(ConsistOf("first!"))
+			status := fetch(comment).Status
+			posted := fakeServer.comment(repo, status.CommentID)
+			Expect(posted).NotTo(BeNil())
+			Expect(status.NodeID).To(Equal(posted.NodeID))
+			Expect(status.HTMLURL).To(Equal(posted.HTMLURL))
+			Expect(status.Repo).To(Equal(repo))
+			Expect(status.IssueNumber).To(Equal(1))
+		})
+
+		It("waits for the GithubIssue to exist", func() {
+			name := fmt.Sprintf("later-%d", time.Now().UnixNano())
+			comment := newComment(name, "waiting")
+			Eventually(ready(comment), timeout, interval).Should(Equal("False/" + g.ReasonIssueNotFound))
+
+			Expect(k8sClient.Create(ctx, &g.GithubIssue{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				Spec:       g.GithubIssueSpec{Title: "later", Repo: repo},
+			})).To(Succeed())
+			Eventually(ready(comment), timeout, interval).Should(Equal("True/" + g.ReasonCommentPosted))
+			Expect(fakeServer.issue(repo, 1).Comments).To(ConsistOf("waiting"))
+		})
+	})
+
+	Context("editing the body", func() {
+		It("edits the comment on github", func() {
+			ghissue := newGithubIssue("edited")
+			comment := newComment(ghissue.Name, "before")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+
+			update(comment, "after")
+			Eventually(githubBody(commentID(comment)), timeout, interval).Should(Equal("after"))
+			Expect(fakeServer.issue(repo, 1).Comments).To(HaveLen(1))
+		})
+
+		It("posts the comment again if it was deleted on github", func() {
+			ghissue := newGithubIssue("deleted by hand")
+			comment := newComment(ghissue.Name, "before")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+			first := fetch(comment).Status.CommentID
+
+			fakeServer.deleteComment(repo, first)
+			update(comment, "after")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(Equal(first))
+			Expect(fakeServer.issue(repo, 1).Comments).To(ConsistOf("after"))
+		})
+
+		It("applies an edit made while the credentials were missing once they are back", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "comment-credentials-"},
+				StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			ghissue := newGithubIssue("credentials come and go", func(spec *g.GithubIssueSpec) {
+				spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+			})
+			comment := newComment(ghissue.Name, "before")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			update(comment, "after")
+			Eventually(func() int64 {
+				fetched := fetch(comment)
+				if meta.IsStatusConditionTrue(fetched.Status.Conditions, g.ConditionReady) {
+					return 0
+				}
+				return fetched.Status.ObservedGeneration
+			}, timeout, interval).Should(Equal(fetch(comment).Generation))
+			Expect(githubBody(commentID(comment))()).To(Equal("before"))
+
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: secret.Name},
+				StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+			})).To(Succeed())
+			Eventually(githubBody(commentID(comment)), timeout, interval).Should(Equal("after"))
+		})
+	})
+
+	Context("deleting the object", func() {
+		It("deletes the comment with Delete", func() {
+			ghissue := newGithubIssue("delete")
+			comment := newComment(ghissue.Name, "short lived")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+			id := fetch(comment).Status.CommentID
+
+			Expect(k8sClient.Delete(ctx, fetch(comment))).To(Succeed())
+			Eventually(gone(comment), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.comment(repo, id).Deleted).To(BeTrue())
+		})
+
+		It("minimizes the comment with Minimize", func() {
+			ghissue := newGithubIssue("minimize")
+			comment := newComment(ghissue.Name, "outdated soon", func(spec *g.GithubIssueCommentSpec) {
+				spec.DeletionPolicy = g.CommentDeletionPolicyMinimize
+			})
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+			id := fetch(comment).Status.CommentID
+
+			Expect(k8sClient.Delete(ctx, fetch(comment))).To(Succeed())
+			Eventually(gone(comment), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.comment(repo, id).Minimized).To(BeTrue())
+			Expect(fakeServer.comment(repo, id).Deleted).To(BeFalse())
+		})
+
+		It("leaves the comment untouched with Retain", func() {
+			ghissue := newGithubIssue("retain")
+			comment := newComment(ghissue.Name, "here to stay", func(spec *g.GithubIssueCommentSpec) {
+				spec.DeletionPolicy = g.CommentDeletionPolicyRetain
+			})
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+			id := fetch(comment).Status.CommentID
+
+			Expect(k8sClient.Delete(ctx, fetch(comment))).To(Succeed())
+			Eventually(gone(comment), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.comment(repo, id).Minimized).To(BeFalse())
+			Expect(fakeServer.comment(repo, id).Deleted).To(BeFalse())
+		})
+
+		It("removes the finalizer when the credentials Secret of the issue is gone", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "comment-credentials-"},
+				StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			ghissue := newGithubIssue("secret gone", func(spec *g.GithubIssueSpec) {
+				spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+			})
+			comment := newComment(ghissue.Name, "abandoned")
+			Eventually(commentID(comment), timeout, interval).ShouldNot(BeZero())
+			id := fetch(comment).Status.CommentID
+
+			Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, fetch(comment))).To(Succeed())
+			Eventually(gone(comment), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.comment(repo, id).Deleted).To(BeFalse())
+		})
+	})
+})
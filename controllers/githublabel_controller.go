@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// GithubLabelReconciler reconciles a GithubLabel object
+type GithubLabelReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// DefaultCredentialsSecret and DefaultEndpoint are the same as GithubIssueReconciler's
+	DefaultCredentialsSecret types.NamespacedName
+	DefaultEndpoint          GithubEndpoint
+	RateLimitThreshold       int
+	// Clients is the same as GithubIssueReconciler's
+	Clients *GithubClients
+}
+
+const labelFinalizerName = "training.redhat.com/label-finalizer"
+
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githublabels,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githublabels/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githublabels/finalizers,verbs=update
+
+func (r *GithubLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("githublabel_name", req.NamespacedName)
+
+	ghlabel := g.GithubLabel{}
+	err := r.Client.Get(ctx, req.NamespacedName, &ghlabel)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log404(logger)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Error reading the object -> Requeue the request.")
+		return ctrl.Result{}, err
+	}
+	deleting := !ghlabel.ObjectMeta.DeletionTimestamp.IsZero()
+	if deleting && !controllerutil.ContainsFinalizer(&ghlabel, labelFinalizerName) {
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&ghlabel, labelFinalizerName) {
+		controllerutil.AddFinalizer(&ghlabel, labelFinalizerName)
+		err = r.Update(ctx, &ghlabel)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	githubClient, err := r.Clients.clientFor(ctx, r.Client, labelTarget(&ghlabel))
+	if err != nil && deleting && isUnrecoverableOnDeletion(err, labelTarget(&ghlabel)) {
+		r.Recorder.Eventf(&ghlabel, corev1.EventTypeWarning, eventLabelAbandoned,
+			"Removed the finalizer without handling label %q in %s: %v", ghlabel.Status.Name, ghlabel.Status.Repo, err)
+		controllerutil.RemoveFinalizer(&ghlabel, labelFinalizerName)
+		return ctrl.Result{}, r.Update(ctx, &ghlabel)
+	}
+	if err != nil {
+		logger.Error(err, "While trying to read the Github credentials")
+		reason, retry := credentialsFailure(err)
+		setStatusCondition(&ghlabel.Status.Conditions, ghlabel.Generation, g.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+		r.Recorder.Event(&ghlabel, corev1.EventTypeWarning, eventCredentialsError, err.Error())
+		_ = r.updateLabelStatus(ctx, &ghlabel)
+		return ctrl.Result{}, retry
+	}
+	ctx1 := context.Background()
+
+	/* the label is bound in another repo than spec.repo (deleted, or spec.repo was edited) -> delete it there if the
+	operator created it, an adopted label is left in place */
+	if ghlabel.Status.Name != "" && (deleting || ghlabel.Status.Repo != ghlabel.Spec.Repo) {
+		if ghlabel.Status.Created {
+			owner, repo, err := splitOwnerRepo(ghlabel.Status.Repo)
+			if err == nil {
+				err = deleteLabelOnGithub(githubClient, ctx1, owner, repo, ghlabel.Status.Name, logger)
+			}
+			if err != nil {
+				logger.Error(err, "While trying to delete label on Github")
+				return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghlabel, &ghlabel.Status.Conditions, g.ReasonDeletionFailed, err)
+			}
+			r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelDeleted, "Deleted label %q in %s", ghlabel.Status.Name, ghlabel.Status.Repo)
+		} else {
+			r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelReleased, "Left adopted label %q in %s", ghlabel.Status.Name, ghlabel.Status.Repo)
+		}
+		ghlabel.Status.Name = ""
+		ghlabel.Status.Repo = ""
+		ghlabel.Status.NodeID = ""
+		ghlabel.Status.Created = false
+	}
+	if deleting {
+		controllerutil.RemoveFinalizer(&ghlabel, labelFinalizerName)
+		err = r.Update(ctx, &ghlabel)
+		if err != nil {
+			logger.Error(err, "r.Update() failed")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	owner, repo, err := splitOwnerRepo(ghlabel.Spec.Repo)
+	if err != nil {
+		return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghlabel, &ghlabel.Status.Conditions, g.ReasonInvalidSpec, err)
+	}
+	/* look the label up under the name it was created with first, so a rename in the spec is applied to it */
+	var label *github.Label
+	if ghlabel.Status.Name != "" && ghlabel.Status.Name != ghlabel.Spec.Name {
+		label, err = getLabelOnGithub(githubClient, ctx1, owner, repo, ghlabel.Status.Name, logger)
+	}
+	if err == nil && label == nil {
+		label, err = getLabelOnGithub(githubClient, ctx1, owner, repo, ghlabel.Spec.Name, logger)
+	}
+	if err != nil {
+		logger.Error(err, "While trying to get label from Github")
+		return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghlabel, &ghlabel.Status.Conditions, g.ReasonLookupFailed, err)
+	}
+	switch {
+	case label == nil:
+		label, err = createLabelOnGithub(githubClient, ctx1, owner, repo, desiredLabel(&ghlabel), logger)
+		if err != nil {
+			logger.Error(err, "While trying to create label on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghlabel, &ghlabel.Status.Conditions, g.ReasonCreateFailed, err)
+		}
+		ghlabel.Status.Created = true
+		r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelCreated, "Created label %q in %s", label.GetName(), ghlabel.Spec.Repo)
+	case !isLabelEqual(label, &ghlabel):
+		if ghlabel.Status.Name == "" {
+			r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelAdopted, "Adopted existing label %q in %s", label.GetName(), ghlabel.Spec.Repo)
+		}
+		label, err = editLabelOnGithub(githubClient, ctx1, owner, repo, label.GetName(), desiredLabel(&ghlabel), logger)
+		if err != nil {
+			logger.Error(err, "While trying to update label on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghlabel, &ghlabel.Status.Conditions, g.ReasonUpdateFailed, err)
+		}
+		r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelUpdated, "Updated label %q in %s", label.GetName(), ghlabel.Spec.Repo)
+	case ghlabel.Status.Name == "":
+		r.Recorder.Eventf(&ghlabel, corev1.EventTypeNormal, eventLabelAdopted, "Adopted existing label %q in %s", label.GetName(), ghlabel.Spec.Repo)
+	}
+	ghlabel.Status.Repo = ghlabel.Spec.Repo
+	ghlabel.Status.Name = label.GetName()
+	ghlabel.Status.NodeID = label.GetNodeID()
+	setStatusCondition(&ghlabel.Status.Conditions, ghlabel.Generation, g.ConditionReady, metav1.ConditionTrue, g.ReasonLabelSynced,
+		fmt.Sprintf("Label %q is in sync with the spec", label.GetName()))
+	return ctrl.Result{}, r.updateLabelStatus(ctx, &ghlabel)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GithubLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clients == nil {
+		r.Clients = NewGithubClients(r.DefaultCredentialsSecret, r.DefaultEndpoint, r.RateLimitThreshold)
+	}
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &g.GithubLabel{}, credentialsRefNameField, func(obj client.Object) []string {
+		ref := obj.(*g.GithubLabel).Spec.CredentialsRef
+		if ref == nil {
+			return nil
+		}
+		return []string{ref.Name}
+	})
+	if err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&g.GithubLabel{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(objectsForSecret(r.Client, r.Log, func() client.ObjectList { return &g.GithubLabelList{} }))).
+		Complete(r)
+}
+
+func labelTarget(ghlabel *g.GithubLabel) githubTarget {
+	return githubTarget{
+		namespace:      ghlabel.Namespace,
+		repo:           ghlabel.Spec.Repo,
+		credentialsRef: ghlabel.Spec.CredentialsRef,
+		baseURL:        ghlabel.Spec.BaseURL,
+		uploadURL:      ghlabel.Spec.UploadURL,
+	}
+}
+
+func (r *GithubLabelReconciler) updateLabelStatus(ctx context.Context, ghlabel *g.GithubLabel) error {
+	ghlabel.Status.ObservedGeneration = ghlabel.Generation
+	err := r.Status().Update(ctx, ghlabel)
+	if err != nil {
+		r.Log.Error(err, "((GithubLabelReconciler)r).Status().Update() failed ")
+		return err
+	}
+	return nil
+}
+
+func desiredLabel(ghlabel *g.GithubLabel) *github.Label {
+	return &github.Label{
+		Name:        github.String(ghlabel.Spec.Name),
+		Color:       github.String(strings.ToLower(ghlabel.Spec.Color)),
+		Description: github.String(ghlabel.Spec.Description),
+	}
+}
+
+/*
+github returns colors in lower case
+*/
+func isLabelEqual(label *github.Label, ghlabel *g.GithubLabel) bool {
+	return label.GetName() == ghlabel.Spec.Name &&
+		strings.EqualFold(label.GetColor(), ghlabel.Spec.Color) &&
+		label.GetDescription() == ghlabel.Spec.Description
+}
+
+/*
+returns nil label (and nil error) if the repo has no label named name.
+Label names may contain spaces and slashes, so they are escaped (go-github does not escape them)
+*/
+func getLabelOnGithub(githubClient *github.Client, ctx context.Context, owner, repo, name string, logger logr.Logger) (*github.Label, error) {
+	label, resp, err := githubClient.Issues.GetLabel(ctx, owner, repo, url.PathEscape(name))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		logGithubError(logger.WithName("getLabelOnGithub()"), err, resp, "Getting github label failed")
+		return nil, err
+	}
+	return label, nil
+}
+
+func createLabelOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, label *github.Label, logger logr.Logger) (*github.Label, error) {
+	created, resp, err := githubClient.Issues.CreateLabel(ctx, owner, repo, label)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
+		logGithubError(logger.WithName("createLabelOnGithub()"), err, resp, "Creation of github label failed")
+		return nil, err
+	}
+	return created, nil
+}
+
+func editLabelOnGithub(githubClient *github.Client, ctx context.Context, owner, repo, name string, label *github.Label, logger logr.Logger) (*github.Label, error) {
+	edited, resp, err := githubClient.Issues.EditLabel(ctx, owner, repo, url.PathEscape(name), label)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		logGithubError(logger.WithName("editLabelOnGithub()"), err, resp, "Updating github label failed")
+		return nil, err
+	}
+	return edited, nil
+}
+
+func deleteLabelOnGithub(githubClient *github.Client, ctx context.Context, owner, repo, name string, logger logr.Logger) error {
+	resp, err := githubClient.Issues.DeleteLabel(ctx, owner, repo, url.PathEscape(name))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil // already gone
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusNoContent) {
+		logGithubError(logger.WithName("deleteLabelOnGithub()"), err, resp, "Deleting github label failed")
+		return err
+	}
+	return nil
+}
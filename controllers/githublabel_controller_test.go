@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+var _ = Describe("GithubLabel controller", func() {
+	ctx := context.Background()
+	var repo string
+
+	BeforeEach(func() {
+		repo = fmt.Sprintf("octo/labels-%d", time.Now().UnixNano())
+		fakeServer.addRepo(repo)
+	})
+
+	newGithubLabel := func(name string, mutate ...func(spec *g.GithubLabelSpec)) *g.GithubLabel {
+		ghlabel := &g.GithubLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githublabel-"},
+			Spec: g.GithubLabelSpec{
+				Repo:        repo,
+				Name:        name,
+				Color:       "D73A4A",
+				Description: "description of " + name,
+			},
+		}
+		for _, m := range mutate {
+			m(&ghlabel.Spec)
+		}
+		Expect(k8sClient.Create(ctx, ghlabel)).To(Succeed())
+		return ghlabel
+	}
+
+	fetch := func(ghlabel *g.GithubLabel) *g.GithubLabel {
+		fetched := &g.GithubLabel{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ghlabel.Namespace, Name: ghlabel.Name}, fetched)).To(Succeed())
+		return fetched
+	}
+
+	ready := func(ghlabel *g.GithubLabel) func() string {
+		return func() string {
+			c := meta.FindStatusCondition(fetch(ghlabel).Status.Conditions, g.ConditionReady)
+			if c == nil {
+				return ""
+			}
+			return string(c.Status) + "/" + c.Reason
+		}
+	}
+
+	update := func(ghlabel *g.GithubLabel, mutate func(spec *g.GithubLabelSpec)) {
+		Eventually(func() error {
+			fetched := fetch(ghlabel)
+			mutate(&fetched.Spec)
+			return k8sClient.Update(ctx, fetched)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	gone := func(ghlabel *g.GithubLabel) func() bool {
+		return func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: ghlabel.Namespace, Name: ghlabel.Name}, &g.GithubLabel{})
+			return errors.IsNotFound(err)
+		}
+	}
+
+	labelExists := func(repo, name string) func() bool {
+		return func() bool {
+			return fakeServer.label(repo, name) != nil
+		}
+	}
+
+	Context("a label that does not exist yet", func() {
+		It("creates the label and records it in the status", func() {
+			ghlabel := newGithubLabel("bug")
+			Eventually(ready(ghlabel), timeout, interval).Should(Equal("True/" + g.ReasonLabelSynced))
+
+			label := fakeServer.label(repo, "bug")
+			Expect(label).NotTo(BeNil())
+			Expect(label.Color).To(Equal("d73a4a"))
+			Expect(label.Description).To(Equal("description of bug"))
+			status := fetch(ghlabel).Status
+			Expect(status.Name).To(Equal("bug"))
+			Expect(status.Repo).To(Equal(repo))
+			Expect(status.NodeID).To(Equal(label.NodeID))
+			Expect(status.Created).To(BeTrue())
+		})
+
+		It("renames the label instead of creating another one", func() {
+			ghlabel := newGithubLabel("needs triage")
+			Eventually(ready(ghlabel), timeout, interval).Should(Equal("True/" + g.ReasonLabelSynced))
+
+			update(ghlabel, func(spec *g.GithubLabelSpec) { spec.Name = "area/triage" })
+			Eventually(labelExists(repo, "area/triage"), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.label(repo, "needs triage")).To(BeNil())
+			Eventually(func() string { return fetch(ghlabel).Status.Name }, timeout, interval).Should(Equal("area/triage"))
+			Expect(fetch(ghlabel).Status.Created).To(BeTrue())
+		})
+
+		It("deletes the label it created when the object is deleted", func() {
+			ghlabel := newGithubLabel("temporary")
+			Eventually(labelExists(repo, "temporary"), timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, fetch(ghlabel))).To(Succeed())
+			Eventually(gone(ghlabel), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.label(repo, "temporary")).To(BeNil())
+		})
+
+		It("moves the label it created when spec.repo changes", func() {
+			ghlabel := newGithubLabel("moving")
+			Eventually(labelExists(repo, "moving"), timeout, interval).Should(BeTrue())
+
+			otherRepo := repo + "-other"
+			fakeServer.addRepo(otherRepo)
+			update(ghlabel, func(spec *g.GithubLabelSpec) { spec.Repo = otherRepo })
+			Eventually(labelExists(otherRepo, "moving"), timeout, interval).Should(BeTrue())
+			Eventually(labelExists(repo, "moving"), timeout, interval).Should(BeFalse())
+		})
+	})
+
+	Context("a label that already exists", func() {
+		It("adopts the label, updates it, and leaves it in the repo when the object is deleted", func() {
+			fakeServer.addLabel(repo, "help wanted", "008672", "set by hand")
+			ghlabel := newGithubLabel("help wanted")
+			Eventually(ready(ghlabel), timeout, interval).Should(Equal("True/" + g.ReasonLabelSynced))
+			Expect(fakeServer.label(repo, "help wanted").Color).To(Equal("d73a4a"))
+			Expect(fakeServer.label(repo, "help wanted").Description).To(Equal("description of help wanted"))
+			Expect(fetch(ghlabel).Status.Created).To(BeFalse())
+
+			Expect(k8sClient.Delete(ctx, fetch(ghlabel))).To(Succeed())
+			Eventually(gone(ghlabel), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.label(repo, "help wanted")).NotTo(BeNil())
+		})
+
+		It("leaves the adopted label in the old repo when spec.repo changes", func() {
+			fakeServer.addLabel(repo, "good first issue", "7057ff", "")
+			ghlabel := newGithubLabel("good first issue")
+			Eventually(ready(ghlabel), timeout, interval).Should(Equal("True/" + g.ReasonLabelSynced))
+
+			otherRepo := repo + "-other"
+			fakeServer.addRepo(otherRepo)
+			update(ghlabel, func(spec *g.GithubLabelSpec) { spec.Repo = otherRepo })
+			Eventually(labelExists(otherRepo, "good first issue"), timeout, interval).Should(BeTrue())
+			Eventually(func() string { return fetch(ghlabel).Status.Repo }, timeout, interval).Should(Equal(otherRepo))
+			Expect(fakeServer.label(repo, "good first issue")).NotTo(BeNil())
+			Expect(fetch(ghlabel).Status.Created).To(BeTrue()) // it did not exist in the other repo
+		})
+	})
+
+	It("removes the finalizer when the credentials Secret is gone", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "label-credentials-"},
+			StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		ghlabel := newGithubLabel("abandoned", func(spec *g.GithubLabelSpec) {
+			spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+		})
+		Eventually(ready(ghlabel), timeout, interval).Should(Equal("True/" + g.ReasonLabelSynced))
+
+		Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, fetch(ghlabel))).To(Succeed())
+		Eventually(gone(ghlabel), timeout, interval).Should(BeTrue())
+		Expect(fakeServer.label(repo, "abandoned")).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// GithubMilestoneReconciler reconciles a GithubMilestone object
+type GithubMilestoneReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// DefaultCredentialsSecret and DefaultEndpoint are the same as GithubIssueReconciler's
+	DefaultCredentialsSecret types.NamespacedName
+	DefaultEndpoint          GithubEndpoint
+	RateLimitThreshold       int
+	// Clients is the same as GithubIssueReconciler's
+	Clients *GithubClients
+}
+
+const (
+	milestoneFinalizerName = "training.redhat.com/milestone-finalizer"
+	// layout of spec.dueOn
+	dueOnLayout = "2006-01-02"
+)
+
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubmilestones,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubmilestones/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubmilestones/finalizers,verbs=update
+
+func (r *GithubMilestoneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Log.WithValues("githubmilestone_name", req.NamespacedName)
+
+	ghmilestone := g.GithubMilestone{}
+	err := r.Client.Get(ctx, req.NamespacedName, &ghmilestone)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			log404(logger)
+			return ctrl.Result{}, nil
+		}
+		logger.Error(err, "Error reading the object -> Requeue the request.")
+		return ctrl.Result{}, err
+	}
+	deleting := !ghmilestone.ObjectMeta.DeletionTimestamp.IsZero()
+	if deleting && !controllerutil.ContainsFinalizer(&ghmilestone, milestoneFinalizerName) {
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&ghmilestone, milestoneFinalizerName) {
+		controllerutil.AddFinalizer(&ghmilestone, milestoneFinalizerName)
+		err = r.Update(ctx, &ghmilestone)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	githubClient, err := r.Clients.clientFor(ctx, r.Client, milestoneTarget(&ghmilestone))
+	if err != nil && deleting && isUnrecoverableOnDeletion(err, milestoneTarget(&ghmilestone)) {
+		r.Recorder.Eventf(&ghmilestone, corev1.EventTypeWarning, eventMilestoneAbandoned,
+			"Removed the finalizer without handling milestone %d in %s: %v", ghmilestone.Status.Number, ghmilestone.Status.Repo, err)
+		controllerutil.RemoveFinalizer(&ghmilestone, milestoneFinalizerName)
+		return ctrl.Result{}, r.Update(ctx, &ghmilestone)
+	}
+	if err != nil {
+		logger.Error(err, "While trying to read the Github credentials")
+		reason, retry := credentialsFailure(err)
+		setStatusCondition(&ghmilestone.Status.Conditions, ghmilestone.Generation, g.ConditionReady, metav1.ConditionFalse, reason, err.Error())
+		r.Recorder.Event(&ghmilestone, corev1.EventTypeWarning, eventCredentialsError, err.Error())
+		_ = r.updateMilestoneStatus(ctx, &ghmilestone)
+		return ctrl.Result{}, retry
+	}
+	ctx1 := context.Background()
+
+	/* the milestone is bound in another repo than spec.repo (deleted, or spec.repo was edited) -> delete it there if
+	the operator created it, an adopted milestone is left in place */
+	if ghmilestone.Status.Number != 0 && (deleting || ghmilestone.Status.Repo != ghmilestone.Spec.Repo) {
+		if ghmilestone.Status.Created {
+			owner, repo, err := splitOwnerRepo(ghmilestone.Status.Repo)
+			if err == nil {
+				err = deleteMilestoneOnGithub(githubClient, ctx1, owner, repo, ghmilestone.Status.Number, logger)
+			}
+			if err != nil {
+				logger.Error(err, "While trying to delete milestone on Github")
+				return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghmilestone, &ghmilestone.Status.Conditions, g.ReasonDeletionFailed, err)
+			}
+			r.Recorder.Eventf(&ghmilestone, corev1.EventTypeNormal, eventMilestoneDeleted, "Deleted milestone %d in %s", ghmilestone.Status.Number, ghmilestone.Status.Repo)
+		} else {
+			r.Recorder.Eventf(&ghmilestone, corev1.EventTypeNormal, eventMilestoneReleased, "Left adopted milestone %d in %s", ghmilestone.Status.Number, ghmilestone.Status.Repo)
+		}
+		ghmilestone.Status = g.GithubMilestoneStatus{Conditions: ghmilestone.Status.Conditions}
+	}
+	if deleting {
+		controllerutil.RemoveFinalizer(&ghmilestone, milestoneFinalizerName)
+		err = r.Update(ctx, &ghmilestone)
+		if err != nil {
+			logger.Error(err, "r.Update() failed")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	owner, repo, err := splitOwnerRepo(ghmilestone.Spec.Repo)
+	if err != nil {
+		return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghmilestone, &ghmilestone.Status.Conditions, g.ReasonInvalidSpec, err)
+	}
+	/* look the milestone up by number once it is bound, by title for first-time adoption */
+	var milestone *github.Milestone
+	if ghmilestone.Status.Number != 0 {
+		milestone, err = getMilestoneByNumber(githubClient, ctx1, owner, repo, ghmilestone.Status.Number, logger)
+	}
+	if err == nil && milestone == nil {
+		milestone, err = getMilestoneByTitle(githubClient, ctx1, owner, repo, ghmilestone.Spec.Title, logger)
+		if err == nil && milestone != nil {
+			ghmilestone.Status.Created = false
+			r.Recorder.Eventf(&ghmilestone, corev1.EventTypeNormal, eventMilestoneAdopted, "Adopted existing milestone %d %s", milestone.GetNumber(), milestone.GetHTMLURL())
+		}
+	}
+	if err != nil {
+		logger.Error(err, "While trying to get milestone from Github")
+		return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghmilestone, &ghmilestone.Status.Conditions, g.ReasonLookupFailed, err)
+	}
+	if milestone == nil {
+		milestone, err = writeMilestoneOnGithub(githubClient, ctx1, owner, repo, 0, &ghmilestone, logger)
+		if err != nil {
+			logger.Error(err, "While trying to create milestone on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghmilestone, &ghmilestone.Status.Conditions, g.ReasonCreateFailed, err)
+		}
+		ghmilestone.Status.Created = true
+		r.Recorder.Eventf(&ghmilestone, corev1.EventTypeNormal, eventMilestoneCreated, "Created milestone %d %s", milestone.GetNumber(), milestone.GetHTMLURL())
+	} else if !isMilestoneEqual(milestone, &ghmilestone) {
+		milestone, err = writeMilestoneOnGithub(githubClient, ctx1, owner, repo, milestone.GetNumber(), &ghmilestone, logger)
+		if err != nil {
+			logger.Error(err, "While trying to update milestone on Github")
+			return readyFailed(ctx, r.Client, r.Recorder, r.Log, &ghmilestone, &ghmilestone.Status.Conditions, g.ReasonUpdateFailed, err)
+		}
+		r.Recorder.Eventf(&ghmilestone, corev1.EventTypeNormal, eventMilestoneUpdated, "Updated milestone %d", milestone.GetNumber())
+	}
+	ghmilestone.Status.Repo = ghmilestone.Spec.Repo
+	ghmilestone.Status.Number = milestone.GetNumber()
+	ghmilestone.Status.NodeID = milestone.GetNodeID()
+	ghmilestone.Status.HTMLURL = milestone.GetHTMLURL()
+	setStatusCondition(&ghmilestone.Status.Conditions, ghmilestone.Generation, g.ConditionReady, metav1.ConditionTrue, g.ReasonMilestoneSynced,
+		fmt.Sprintf("Milestone %d is in sync with the spec", milestone.GetNumber()))
+	return ctrl.Result{}, r.updateMilestoneStatus(ctx, &ghmilestone)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *GithubMilestoneReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clients == nil {
+		r.Clients = NewGithubClients(r.DefaultCredentialsSecret, r.DefaultEndpoint, r.RateLimitThreshold)
+	}
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &g.GithubMilestone{}, credentialsRefNameField, func(obj client.Object) []string {
+		ref := obj.(*g.GithubMilestone).Spec.CredentialsRef
+		if ref == nil {
+			return nil
+		}
+		return []string{ref.Name}
+	})
+	if err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&g.GithubMilestone{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(objectsForSecret(r.Client, r.Log, func() client.ObjectList { return &g.GithubMilestoneList{} }))).
+		Complete(r)
+}
+
+func milestoneTarget(ghmilestone *g.GithubMilestone) githubTarget {
+	return githubTarget{
+		namespace:      ghmilestone.Namespace,
+		repo:           ghmilestone.Spec.Repo,
+		credentialsRef: ghmilestone.Spec.CredentialsRef,
+		baseURL:        ghmilestone.Spec.BaseURL,
+		uploadURL:      ghmilestone.Spec.UploadURL,
+	}
+}
+
+func (r *GithubMilestoneReconciler) updateMilestoneStatus(ctx context.Context, ghmilestone *g.GithubMilestone) error {
+	ghmilestone.Status.ObservedGeneration = ghmilestone.Generation
+	err := r.Status().Update(ctx, ghmilestone)
+	if err != nil {
+		r.Log.Error(err, "((GithubMilestoneReconciler)r).Status().Update() failed ")
+		return err
+	}
+	return nil
+}
+
+func desiredMilestoneState(ghmilestone *g.GithubMilestone) string {
+	if ghmilestone.Spec.State == "" {
+		return "open"
+	}
+	return ghmilestone.Spec.State
+}
+
+/*
+github stores the due date in the Pacific time zone, so the time of day it returns varies. Only the date is compared
+*/
+func isMilestoneEqual(milestone *github.Milestone, ghmilestone *g.GithubMilestone) bool {
+	dueOn := ""
+	if milestone.DueOn != nil {
+		dueOn = milestone.DueOn.UTC().Format(dueOnLayout)
+	}
+	return milestone.GetTitle() == ghmilestone.Spec.Title &&
+		milestone.GetDescription() == ghmilestone.Spec.Description &&
+		milestone.GetState() == desiredMilestoneState(ghmilestone) &&
+		dueOn == ghmilestone.Spec.DueOn
+}
+
+// milestoneRequest is the body of a milestone creation or edit. go-github's Milestone omits a nil due date, so it can
+// not remove one
+type milestoneRequest struct {
+	Title       string     `json:"title"`
+	State       string     `json:"state"`
+	Description string     `json:"description"`
+	DueOn       *time.Time `json:"due_on"`
+}
+
+/*
+creates the milestone if number is 0, otherwise edits milestone number
+*/
+func writeMilestoneOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, number int, ghmilestone *g.GithubMilestone, logger logr.Logger) (*github.Milestone, error) {
+	body := &milestoneRequest{
+		Title:       ghmilestone.Spec.Title,
+		State:       desiredMilestoneState(ghmilestone),
+		Description: ghmilestone.Spec.Description,
+	}
+	if ghmilestone.Spec.DueOn != "" {
+		dueOn, err := time.Parse(dueOnLayout, ghmilestone.Spec.DueOn)
+		if err != nil {
+			return nil, fmt.Errorf("spec.dueOn %q is not a date: %w", ghmilestone.Spec.DueOn, err)
+		}
+		dueOn = dueOn.Add(12 * time.Hour) // noon UTC is the same date in the Pacific time zone
+		body.DueOn = &dueOn
+	}
+	method, url, wantStatus := "POST", fmt.Sprintf("repos/%v/%v/milestones", owner, repo), http.StatusCreated
+	if number != 0 {
+		method, url, wantStatus = "PATCH", fmt.Sprintf("repos/%v/%v/milestones/%d", owner, repo, number), http.StatusOK
+	}
+	req, err := githubClient.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	milestone := new(github.Milestone)
+	resp, err := githubClient.Do(ctx, req, milestone)
+	if err != nil || (resp != nil && resp.StatusCode != wantStatus) {
+		logGithubError(logger.WithName("writeMilestoneOnGithub()"), err, resp, "Writing github milestone failed")
+		return nil, err
+	}
+	return milestone, nil
+}
+
+/*
+returns nil milestone (and nil error) if the milestone does not exist anymore
+*/
+func getMilestoneByNumber(githubClient *github.Client, ctx context.Context, owner, repo string, number int, logger logr.Logger) (*github.Milestone, error) {
+	milestone, resp, err := githubClient.Issues.GetMilestone(ctx, owner, repo, number)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		logGithubError(logger.WithName("getMilestoneByNumber()"), err, resp, "Getting github milestone failed")
+		return nil, err
+	}
+	return milestone, nil
+}
+
+/*
+milestone titles are unique per repo. Returns nil milestone (and nil error) if there is no milestone titled title
+*/
+func getMilestoneByTitle(githubClient *github.Client, ctx context.Context, owner, repo, title string, logger logr.Logger) (*github.Milestone, error) {
+	opts := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		milestones, resp, err := githubClient.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+			logGithubError(logger.WithName("getMilestoneByTitle()"), err, resp, "Listing github milestones failed")
+			return nil, err
+		}
+		for _, milestone := range milestones {
+			if milestone.GetTitle() == title {
+				return milestone, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func deleteMilestoneOnGithub(githubClient *github.Client, ctx context.Context, owner, repo string, number int, logger logr.Logger) error {
+	resp, err := githubClient.Issues.DeleteMilestone(ctx, owner, repo, number)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil // already gone
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusNoContent) {
+		logGithubError(logger.WithName("deleteMilestoneOnGithub()"), err, resp, "Deleting github milestone failed")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+var _ = Describe("GithubMilestone controller", func() {
+	ctx := context.Background()
+	var repo string
+
+	BeforeEach(func() {
+		repo = fmt.Sprintf("octo/milestones-%d", time.Now().UnixNano())
+		fakeServer.addRepo(repo)
+	})
+
+	newGithubMilestone := func(title string, mutate ...func(spec *g.GithubMilestoneSpec)) *g.GithubMilestone {
+		ghmilestone := &g.GithubMilestone{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githubmilestone-"},
+			Spec: g.GithubMilestoneSpec{
+				Repo:        repo,
+				Title:       title,
+				Description: "description of " + title,
+			},
+		}
+		for _, m := range mutate {
+			m(&ghmilestone.Spec)
+		}
+		Expect(k8sClient.Create(ctx, ghmilestone)).To(Succeed())
+		return ghmilestone
+	}
+
+	fetch := func(ghmilestone *g.GithubMilestone) *g.GithubMilestone {
+		fetched := &g.GithubMilestone{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ghmilestone.Namespace, Name: ghmilestone.Name}, fetched)).To(Succeed())
+		return fetched
+	}
+
+	ready := func(ghmilestone *g.GithubMilestone) func() string {
+		return func() string {
+			c := meta.FindStatusCondition(fetch(ghmilestone).Status.Conditions, g.ConditionReady)
+			if c == nil {
+				return ""
+			}
+			return string(c.Status) + "/" + c.Reason
+		}
+	}
+
+	update := func(ghmilestone *g.GithubMilestone, mutate func(spec *g.GithubMilestoneSpec)) {
+		Eventually(func() error {
+			fetched := fetch(ghmilestone)
+			mutate(&fetched.Spec)
+			return k8sClient.Update(ctx, fetched)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	gone := func(ghmilestone *g.GithubMilestone) func() bool {
+		return func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: ghmilestone.Namespace, Name: ghmilestone.Name}, &g.GithubMilestone{})
+			return errors.IsNotFound(err)
+		}
+	}
+
+	Context("a milestone that does not exist yet", func() {
+		It("creates the milestone and records it in the status", func() {
+			ghmilestone := newGithubMilestone("v1.0", func(spec *g.GithubMilestoneSpec) {
+				spec.DueOn = "2026-12-31"
+			})
+			Eventually(ready(ghmilestone), timeout, interval).Should(Equal("True/" + g.ReasonMilestoneSynced))
+
+			milestone := fakeServer.milestone(repo, 1)
+			Expect(milestone).NotTo(BeNil())
+			Expect(milestone.Title).To(Equal("v1.0"))
+			Expect(milestone.Description).To(Equal("description of v1.0"))
+			Expect(milestone.State).To(Equal("open"))
+			Expect(milestone.DueOn).NotTo(BeNil())
+			Expect(milestone.DueOn.UTC().Format(dueOnLayout)).To(Equal("2026-12-31"))
+			status := fetch(ghmilestone).Status
+			Expect(status.Number).To(Equal(1))
+			Expect(status.Repo).To(Equal(repo))
+			Expect(status.HTMLURL).To(Equal(milestone.HTMLURL))
+			Expect(status.Created).To(BeTrue())
+		})
+
+		It("retitles and closes the milestone it is bound to", func() {
+			ghmilestone := newGithubMilestone("v2.0")
+			Eventually(ready(ghmilestone), timeout, interval).Should(Equal("True/" + g.ReasonMilestoneSynced))
+
+			update(ghmilestone, func(spec *g.GithubMilestoneSpec) {
+				spec.Title = "v2.0 final"
+				spec.State = "closed"
+			})
+			Eventually(func() string { return fakeServer.milestone(repo, 1).Title }, timeout, interval).Should(Equal("v2.0 final"))
+			Expect(fakeServer.milestone(repo, 1).State).To(Equal("closed"))
+			Expect(fakeServer.milestone(repo, 2)).To(BeNil())
+			Expect(fetch(ghmilestone).Status.Number).To(Equal(1))
+		})
+
+		It("deletes the milestone it created when the object is deleted", func() {
+			ghmilestone := newGithubMilestone("temporary")
+			Eventually(func() int { return fetch(ghmilestone).Status.Number }, timeout, interval).Should(Equal(1))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghmilestone))).To(Succeed())
+			Eventually(gone(ghmilestone), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.milestone(repo, 1)).To(BeNil())
+		})
+	})
+
+	Context("a milestone that already exists", func() {
+		It("adopts the milestone with the same title, and leaves it in the repo when the object is deleted", func() {
+			number := fakeServer.addMilestone(repo, "backlog")
+			ghmilestone := newGithubMilestone("backlog")
+			Eventually(ready(ghmilestone), timeout, interval).Should(Equal("True/" + g.ReasonMilestoneSynced))
+			Expect(fetch(ghmilestone).Status.Number).To(Equal(number))
+			Expect(fetch(ghmilestone).Status.Created).To(BeFalse())
+			Expect(fakeServer.milestone(repo, number).Description).To(Equal("description of backlog"))
+
+			Expect(k8sClient.Delete(ctx, fetch(ghmilestone))).To(Succeed())
+			Eventually(gone(ghmilestone), timeout, interval).Should(BeTrue())
+			Expect(fakeServer.milestone(repo, number)).NotTo(BeNil())
+		})
+	})
+
+	Context("a GithubIssue with spec.milestoneRef", func() {
+		newGithubIssue := func(title, milestoneRef string) *g.GithubIssue {
+			ghissue := &g.GithubIssue{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "githubissue-"},
+				Spec: g.GithubIssueSpec{
+					Title:        title,
+					Repo:         repo,
+					Desc:         "description of " + title,
+					MilestoneRef: &g.LocalObjectReference{Name: milestoneRef},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ghissue)).To(Succeed())
+			return ghissue
+		}
+
+		milestoneResolved := func(ghissue *g.GithubIssue) func() string {
+			return func() string {
+				fetched := &g.GithubIssue{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ghissue.Namespace, Name: ghissue.Name}, fetched)).To(Succeed())
+				c := meta.FindStatusCondition(fetched.Status.Conditions, g.ConditionMilestoneResolved)
+				if c == nil {
+					return ""
+				}
+				return string(c.Status) + "/" + c.Reason
+			}
+		}
+
+		issueMilestone := func() int {
+			issue := fakeServer.issue(repo, 1)
+			if issue == nil || issue.Milestone == nil {
+				return 0
+			}
+			return issue.Milestone.Number
+		}
+
+		It("waits for the GithubMilestone, then files the issue in the milestone", func() {
+			name := fmt.Sprintf("sprint-%d", time.Now().UnixNano())
+			ghissue := newGithubIssue("planned", name)
+			Eventually(milestoneResolved(ghissue), timeout, interval).Should(Equal("False/" + g.ReasonMilestoneNotFound))
+			Expect(fakeServer.issueCount(repo)).To(Equal(0))
+
+			ghmilestone := &g.GithubMilestone{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				Spec:       g.GithubMilestoneSpec{Repo: repo, Title: "sprint"},
+			}
+			Expect(k8sClient.Create(ctx, ghmilestone)).To(Succeed())
+			Eventually(milestoneResolved(ghissue), timeout, interval).Should(Equal("True/" + g.ReasonMilestoneResolved))
+			Eventually(issueMilestone, timeout, interval).Should(Equal(1))
+		})
+
+		It("does not resolve a GithubMilestone of another repo", func() {
+			name := fmt.Sprintf("elsewhere-%d", time.Now().UnixNano())
+			fakeServer.addRepo(repo + "-other")
+			ghmilestone := &g.GithubMilestone{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+				Spec:       g.GithubMilestoneSpec{Repo: repo + "-other", Title: "elsewhere"},
+			}
+			Expect(k8sClient.Create(ctx, ghmilestone)).To(Succeed())
+			ghissue := newGithubIssue("misfiled", name)
+			Eventually(milestoneResolved(ghissue), timeout, interval).Should(Equal("False/" + g.ReasonMilestoneRepoMismatch))
+			Expect(fakeServer.issueCount(repo)).To(Equal(0))
+		})
+	})
+
+	It("removes the finalizer when the credentials Secret is gone", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", GenerateName: "milestone-credentials-"},
+			StringData: map[string]string{defaultCredentialsKey: fakeGithubToken},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		ghmilestone := newGithubMilestone("abandoned", func(spec *g.GithubMilestoneSpec) {
+			spec.CredentialsRef = &g.CredentialsRef{Name: secret.Name}
+		})
+		Eventually(ready(ghmilestone), timeout, interval).Should(Equal("True/" + g.ReasonMilestoneSynced))
+
+		Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, fetch(ghmilestone))).To(Succeed())
+		Eventually(gone(ghmilestone), timeout, interval).Should(BeTrue())
+		Expect(fakeServer.milestone(repo, 1)).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+	"sigs.k8s.io/yaml"
+)
+
+// ImportOptions selects the github issues ImportIssues turns into GithubIssue manifests
+type ImportOptions struct {
+	// Repo is owner/repo
+	Repo string
+	// State is open, closed or all
+	State string
+	// Namespace of the generated objects, omitted if empty
+	Namespace string
+	// PageSize and MaxIssues bound the listing like --github-list-page-size and --github-list-max-issues
+	PageSize  int
+	MaxIssues int
+	// Token and Endpoint are the credentials and the Github instance to read the issues with. A Github Enterprise
+	// Server endpoint is written into spec.baseURL (and spec.uploadURL) of every manifest
+	Token    string
+	Endpoint GithubEndpoint
+}
+
+// IssueManifest is a GithubIssue as it is committed to Git: no status, no server-populated metadata
+type IssueManifest struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   ManifestMetadata  `json:"metadata"`
+	Spec       g.GithubIssueSpec `json:"spec"`
+}
+
+// ManifestMetadata is the metadata of an IssueManifest
+type ManifestMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+/*
+pages through the repository and returns a manifest per issue (pull requests are skipped),
+bound to its issue by spec.issueNumber so applying it adopts the issue instead of filing a duplicate.
+truncated is true if the repository has more issues than opts.MaxIssues
+*/
+func ImportIssues(ctx context.Context, opts ImportOptions, logger logr.Logger) (manifests []IssueManifest, truncated bool, err error) {
+	_, repo, err := splitOwnerRepo(opts.Repo)
+	if err != nil {
+		return nil, false, fmt.Errorf("--repo must be owner/repo, got %q", opts.Repo)
+	}
+	githubClient, err := getGithubClient(opts.Token, opts.Endpoint, nil, "")
+	if err != nil {
+		return nil, false, err
+	}
+	listOpts := tracker.ListOptions{PageSize: opts.PageSize, MaxIssues: opts.MaxIssues, State: opts.State}
+	if listOpts.PageSize <= 0 {
+		listOpts.PageSize = defaultListPageSize
+	}
+	if listOpts.MaxIssues <= 0 {
+		listOpts.MaxIssues = defaultListMaxIssues
+	}
+	list, err := tracker.NewGitHub(githubClient, logger).List(ctx, opts.Repo, listOpts)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, issue := range list.Issues {
+		if issue.PullRequest {
+			continue
+		}
+		manifests = append(manifests, IssueManifest{
+			APIVersion: g.GroupVersion.String(),
+			Kind:       "GithubIssue",
+			Metadata:   ManifestMetadata{Name: manifestName(repo, issue.Number), Namespace: opts.Namespace},
+			Spec: g.GithubIssueSpec{
+				Title:          issue.Title,
+				Repo:           opts.Repo,
+				Desc:           bodyWithoutMarker(issue.Body),
+				IssueNumber:    issue.Number,
+				AdoptionPolicy: g.AdoptionPolicyNever,
+				State:          issue.State,
+				Labels:         issue.Labels,
+				Assignees:      issue.Assignees,
+				BaseURL:        opts.Endpoint.BaseURL,
+				UploadURL:      opts.Endpoint.UploadURL,
+			},
+		})
+	}
+	return manifests, list.Truncated, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+/*
+<repo>-<number>, lowercased and reduced to the characters allowed in object names
+*/
+func manifestName(repo string, number int) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(repo), "-"), "-")
+	suffix := "-" + strconv.Itoa(number)
+	if len(name)+len(suffix) > 253 {
+		name = name[:253-len(suffix)]
+	}
+	return name + suffix
+}
+
+/*
+writes the manifests as a single multi-document YAML stream
+*/
+func WriteManifests(w io.Writer, manifests []IssueManifest) error {
+	for _, manifest := range manifests {
+		out, err := yaml.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "---\n%s", out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+writes every manifest to <dir>/<name>.yaml
+*/
+func WriteManifestsToDir(dir string, manifests []IssueManifest) error {
+	for _, manifest := range manifests {
+		out, err := yaml.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, manifest.Metadata.Name+".yaml"), out, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+var _ = Describe("Import", func() {
+	ctx := context.Background()
+	var repo string
+	var endpoint GithubEndpoint
+
+	BeforeEach(func() {
+		repo = fmt.Sprintf("octo/Import_%d.go", time.Now().UnixNano())
+		fakeServer.addRepo(repo)
+		endpoint = fakeServer.endpoint()
+		endpoint.UploadURL = fakeServer.server.URL + "/api/uploads/"
+	})
+
+	importOptions := func() ImportOptions {
+		return ImportOptions{Repo: repo, State: "all", Namespace: "team", Token: fakeGithubToken, Endpoint: endpoint}
+	}
+
+	Context("ImportIssues", func() {
+		It("turns every issue into a manifest adopting it, and skips pull requests", func() {
+			fakeServer.addIssue(repo, "filed by hand", "written by a human")
+			fakeServer.addIssue(repo, "filed by the operator", "written by the operator\n\n"+fmt.Sprintf(ownershipMarkerFormat, "default/old"))
+			pullRequest := fakeServer.addIssue(repo, "a pull request", "")
+			fakeServer.editIssue(repo, pullRequest, func(issue *fakeIssue) {
+				issue.PullRequest = json.RawMessage(`{"url": "https://example.com/pulls/3"}`)
+			})
+			closed := fakeServer.addIssue(repo, "done", "")
+			fakeServer.editIssue(repo, closed, func(issue *fakeIssue) {
+				issue.State = "closed"
+				issue.Labels = []fakeLabel{{Name: "bug"}}
+				issue.Assignees = []fakeUser{{Login: "octocat"}}
+			})
+
+			manifests, truncated, err := ImportIssues(ctx, importOptions(), logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(truncated).To(BeFalse())
+			byNumber := map[int]IssueManifest{}
+			for _, manifest := range manifests {
+				byNumber[manifest.Spec.IssueNumber] = manifest
+			}
+			Expect(byNumber).To(HaveLen(3))
+			Expect(byNumber).NotTo(HaveKey(pullRequest))
+
+			manifest := byNumber[1]
+			Expect(manifest.APIVersion).To(Equal(g.GroupVersion.String()))
+			Expect(manifest.Kind).To(Equal("GithubIssue"))
+			Expect(manifest.Metadata).To(Equal(ManifestMetadata{Name: manifestName(repo[len("octo/"):], 1), Namespace: "team"}))
+			Expect(manifest.Spec.Title).To(Equal("filed by hand"))
+			Expect(manifest.Spec.Desc).To(Equal("written by a human"))
+			Expect(manifest.Spec.Repo).To(Equal(repo))
+			Expect(manifest.Spec.AdoptionPolicy).To(Equal(g.AdoptionPolicyNever))
+			Expect(manifest.Spec.State).To(Equal("open"))
+			Expect(manifest.Spec.BaseURL).To(Equal(endpoint.BaseURL))
+			Expect(manifest.Spec.UploadURL).To(Equal(endpoint.UploadURL))
+
+			Expect(byNumber[2].Spec.Desc).To(Equal("written by the operator"))
+			Expect(byNumber[closed].Spec.State).To(Equal("closed"))
+			Expect(byNumber[closed].Spec.Labels).To(ConsistOf("bug"))
+			Expect(byNumber[closed].Spec.Assignees).To(ConsistOf("octocat"))
+		})
+
+		It("reports a repository with more issues than MaxIssues as truncated", func() {
+			for i := 0; i < 3; i++ {
+				fakeServer.addIssue(repo, fmt.Sprintf("issue %d", i), "")
+			}
+			opts := importOptions()
+			opts.PageSize, opts.MaxIssues = 1, 2
+			manifests, truncated, err := ImportIssues(ctx, opts, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(truncated).To(BeTrue())
+			Expect(manifests).To(HaveLen(2))
+		})
+
+		It("rejects a repo that is not owner/repo", func() {
+			opts := importOptions()
+			opts.Repo = "no-owner"
+			_, _, err := ImportIssues(ctx, opts, logr.Discard())
+			Expect(err).To(MatchError(ContainSubstring("--repo must be owner/repo")))
+		})
+	})
+
+	It("writes every manifest to a file named after it", func() {
+		closed := fakeServer.addIssue(repo, "done", "fixed")
+		fakeServer.editIssue(repo, closed, func(issue *fakeIssue) {
+			issue.State = "closed"
+			issue.Labels = []fakeLabel{{Name: "bug"}}
+		})
+		fakeServer.addIssue(repo, "open", "")
+		manifests, _, err := ImportIssues(ctx, importOptions(), logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		dir, err := ioutil.TempDir("", "import-")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(WriteManifestsToDir(dir, manifests)).To(Succeed())
+
+		name := manifestName(repo[len("octo/"):], closed)
+		files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(ConsistOf(filepath.Join(dir, name+".yaml"), filepath.Join(dir, manifestName(repo[len("octo/"):], 2)+".yaml")))
+		out, err := ioutil.ReadFile(filepath.Join(dir, name+".yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(strings.Join([]string{
+			"apiVersion: example.training.redhat.com/v1alpha1",
+			"kind: GithubIssue",
+			"metadata:",
+			"  name: " + name,
+			"  namespace: team",
+			"spec:",
+			"  adoptionPolicy: Never",
+			"  baseURL: " + endpoint.BaseURL,
+			"  description: fixed",
+			"  issueNumber: " + strconv.Itoa(closed),
+			"  labels:",
+			"  - bug",
+			"  repo: " + repo,
+			"  state: closed",
+			"  title: done",
+			"  uploadURL: " + endpoint.UploadURL,
+			"",
+		}, "\n")))
+
+		/* the files apply as they are */
+		parsed := IssueManifest{}
+		Expect(yaml.UnmarshalStrict(out, &parsed)).To(Succeed())
+		Expect(parsed.Spec.IssueNumber).To(Equal(closed))
+		Expect(manifests).To(ContainElement(parsed))
+	})
+
+	table.DescribeTable("manifestName",
+		func(repo string, number int, name string) {
+			Expect(manifestName(repo, number)).To(Equal(name))
+		},
+		table.Entry("plain", "hello-world", 7, "hello-world-7"),
+		table.Entry("upper case, dots and underscores", "Hello_World.go", 12, "hello-world-go-12"),
+		table.Entry("leading and trailing separators", "_.hello._", 1, "hello-1"),
+		table.Entry("truncated to 253 characters", strings.Repeat("a", 300), 42, strings.Repeat("a", 250)+"-42"),
+	)
+})
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leejoebarak/githubissue-operator/tracker"
+)
+
+const defaultCacheInterval = time.Minute
+
+// issueCache holds the issues of every repository the reconciler works on, so all GithubIssue objects pointing at
+// the same repository share one listing instead of re-listing the repository on every reconcile.
+// A repository is listed in full once, and afterwards refreshed at most once per interval by asking the tracker only
+// for the issues updated since the last refresh (with the ETag of the previous answer, so an unchanged repository
+// costs a 304 that does not count against Github's rate limit).
+type issueCache struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	repos map[repoKey]*repoIssues
+}
+
+// repoKey identifies a cached repository. Repositories are cached per credential (see credentialsScope), so an
+// object never gets to see issues listed with someone else's token
+type repoKey struct {
+	scope string
+	repo  string
+}
+
+// repoIssues is the cached state of a single repository
+type repoIssues struct {
+	mu sync.Mutex // serializes refreshes of this repository
+
+	issues    map[int]*tracker.Issue // by issue number
+	truncated bool                   // the full listing hit ListOptions.MaxIssues
+	listed    bool                   // the full listing was done
+	refreshed time.Time              // local time of the last refresh
+	since     time.Time              // newest UpdatedAt seen so far (the tracker's clock)
+	etag      string                 // ETag of the last incremental listing
+	stale     bool                   // invalidated by a write, refresh regardless of the interval
+}
+
+func newIssueCache(interval time.Duration) *issueCache {
+	if interval <= 0 {
+		interval = defaultCacheInterval
+	}
+	return &issueCache{interval: interval, repos: map[repoKey]*repoIssues{}}
+}
+
+func (c *issueCache) entry(scope, repo string) *repoIssues {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := repoKey{scope: scope, repo: repo}
+	e, ok := c.repos[key]
+	if !ok {
+		e = &repoIssues{issues: map[int]*tracker.Issue{}}
+		c.repos[key] = e
+	}
+	return e
+}
+
+/*
+returns the issues of repo as seen with the credential scope (newest first), refreshing them first if the interval elapsed or the repository
+was invalidated. truncated is true if the repository has more issues than opts.MaxIssues
+*/
+func (c *issueCache) list(scope string, issueTracker tracker.IssueTracker, ctx context.Context, repo string, opts tracker.ListOptions) (issues []*tracker.Issue, truncated bool, err error) {
+	e := c.entry(scope, repo)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.listed {
+		all, err := issueTracker.List(ctx, repo, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		e.merge(all.Issues)
+		e.listed, e.truncated, e.refreshed, e.stale = true, all.Truncated, time.Now(), false
+	} else if e.stale || time.Since(e.refreshed) >= c.interval {
+		opts.Since, opts.ETag = e.since, e.etag
+		updated, err := issueTracker.List(ctx, repo, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		e.merge(updated.Issues)
+		e.etag, e.refreshed, e.stale = updated.ETag, time.Now(), false
+	}
+	return e.sorted(), e.truncated, nil
+}
+
+/*
+called after every write to owner/repo, so the next reconcile of the repository (with any credential) sees the change
+*/
+func (c *issueCache) invalidate(repo string) {
+	for _, e := range c.entries(repo) {
+		e.mu.Lock()
+		e.stale = true
+		e.mu.Unlock()
+	}
+}
+
+/*
+drops an issue that is gone (deleted, or transferred to another repository) from every listing of repo. Incremental
+listings never report such issues, so without this the cache would keep returning them forever
+*/
+func (c *issueCache) evict(repo string, number int) {
+	for _, e := range c.entries(repo) {
+		e.mu.Lock()
+		delete(e.issues, number)
+		e.mu.Unlock()
+	}
+}
+
+func (c *issueCache) entries(repo string) []*repoIssues {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := []*repoIssues{}
+	for key, e := range c.repos {
+		if key.repo == repo {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (e *repoIssues) merge(issues []*tracker.Issue) {
+	for _, issue := range issues {
+		e.issues[issue.Number] = issue
+		if issue.UpdatedAt.After(e.since) {
+			e.since = issue.UpdatedAt
+		}
+	}
+}
+
+func (e *repoIssues) sorted() []*tracker.Issue {
+	issues := make([]*tracker.Issue, 0, len(e.issues))
+	for _, issue := range e.issues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number > issues[j].Number })
+	return issues
+}
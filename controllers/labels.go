@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"strings"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+)
+
+/*
+the labels the github issue should have according to ghissue's LabelPolicy:
+  - Authoritative: exactly spec.labels
+  - OwnedOnly: the issue's current labels, minus the ones the operator added (status.managedLabels) that are no longer
+    in spec.labels, plus spec.labels
+*/
+func desiredLabels(issue *tracker.Issue, ghissue *g.GithubIssue) []string {
+	if ghissue.Spec.LabelPolicy != g.LabelPolicyOwnedOnly {
+		return ghissue.Spec.Labels
+	}
+	inSpec := caseInsensitiveSet(ghissue.Spec.Labels)
+	dropped := map[string]bool{}
+	for _, label := range ghissue.Status.ManagedLabels {
+		if !inSpec[strings.ToLower(label)] {
+			dropped[strings.ToLower(label)] = true
+		}
+	}
+	desired := append([]string{}, ghissue.Spec.Labels...)
+	for _, name := range issue.Labels {
+		if !dropped[strings.ToLower(name)] && !inSpec[strings.ToLower(name)] {
+			desired = append(desired, name)
+		}
+	}
+	return desired
+}
+
+/*
+label names are case insensitive on github
+*/
+func isLabelsEqual(issue *tracker.Issue, labels []string) bool {
+	return equalNameSets(issue.Labels, labels)
+}
+
+func equalNameSets(a, b []string) bool {
+	setA, setB := caseInsensitiveSet(a), caseInsensitiveSet(b)
+	if len(setA) != len(setB) {
+		return false
+	}
+	for label := range setA {
+		if !setB[label] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+label names and user logins are case insensitive on github
+*/
+func caseInsensitiveSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = true
+	}
+	return set
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// milestoneRefNameField indexes GithubIssue objects by the name of the GithubMilestone they belong to
+const milestoneRefNameField = ".spec.milestoneRef.name"
+
+/*
+resolves spec.milestoneRef to the number of the github milestone and records the outcome in the MilestoneResolved
+condition. Returns 0 without spec.milestoneRef. ready is false while the milestone does not exist on github (yet),
+the GithubMilestone watch requeues the issue once it does
+*/
+func (r *GithubIssueReconciler) resolveMilestone(ctx context.Context, ghissue *g.GithubIssue) (number int, ready bool, err error) {
+	ref := ghissue.Spec.MilestoneRef
+	if ref == nil {
+		removeCondition(ghissue, g.ConditionMilestoneResolved)
+		return 0, true, nil
+	}
+	ghmilestone := g.GithubMilestone{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: ghissue.Namespace, Name: ref.Name}, &ghmilestone)
+	switch {
+	case errors.IsNotFound(err):
+		setCondition(ghissue, g.ConditionMilestoneResolved, metav1.ConditionFalse, g.ReasonMilestoneNotFound,
+			fmt.Sprintf("GithubMilestone %s not found", ref.Name))
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	case ghmilestone.Spec.Repo != ghissue.Spec.Repo:
+		setCondition(ghissue, g.ConditionMilestoneResolved, metav1.ConditionFalse, g.ReasonMilestoneRepoMismatch,
+			fmt.Sprintf("GithubMilestone %s is in %s, not in %s", ref.Name, ghmilestone.Spec.Repo, ghissue.Spec.Repo))
+		return 0, false, nil
+	case ghmilestone.Status.Number == 0 || ghmilestone.Status.Repo != ghissue.Spec.Repo:
+		setCondition(ghissue, g.ConditionMilestoneResolved, metav1.ConditionFalse, g.ReasonMilestoneNotReady,
+			fmt.Sprintf("GithubMilestone %s has no github milestone yet", ref.Name))
+		return 0, false, nil
+	}
+	setCondition(ghissue, g.ConditionMilestoneResolved, metav1.ConditionTrue, g.ReasonMilestoneResolved,
+		fmt.Sprintf("GithubMilestone %s is milestone %d", ref.Name, ghmilestone.Status.Number))
+	return ghmilestone.Status.Number, true, nil
+}
+
+/*
+the milestone the github issue should have: the resolved spec.milestoneRef. Without spec.milestoneRef a milestone
+the operator assigned is removed, a milestone set by hand is left alone
+*/
+func desiredMilestone(issue *tracker.Issue, ghissue *g.GithubIssue, resolved int) int {
+	if ghissue.Spec.MilestoneRef != nil {
+		return resolved
+	}
+	current := issue.Milestone
+	if current == ghissue.Status.Milestone {
+		return 0
+	}
+	return current
+}
+
+/*
+maps a GithubMilestone to the GithubIssue objects referencing it, so waiting issues proceed once it exists
+*/
+func (r *GithubIssueReconciler) githubIssuesForMilestone(ghmilestone client.Object) []reconcile.Request {
+	ghissues := g.GithubIssueList{}
+	err := r.List(context.Background(), &ghissues, client.InNamespace(ghmilestone.GetNamespace()), client.MatchingFields{milestoneRefNameField: ghmilestone.GetName()})
+	if err != nil {
+		r.Log.Error(err, "Listing the GithubIssues of a GithubMilestone failed", "githubmilestone", ghmilestone.GetNamespace()+"/"+ghmilestone.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(ghissues.Items))
+	for _, ghissue := range ghissues.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ghissue.Namespace, Name: ghissue.Name}})
+	}
+	return requests
+}
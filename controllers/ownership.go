@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+)
+
+// the ownership marker is an HTML comment at the end of the body of every issue the operator manages. Github does not
+// render it, and it names the GithubIssue object owning the issue, so the operator can tell its own issues apart from
+// issues filed by humans (or owned by another object)
+const ownershipMarkerFormat = "<!-- githubissue-operator: %s -->"
+
+var ownershipMarkerRegexp = regexp.MustCompile(`\s*<!-- githubissue-operator: (\S+) -->\s*$`)
+
+/*
+namespace/name of the GithubIssue object, as written into the ownership marker
+*/
+func ownerKey(ghissue *g.GithubIssue) string {
+	return ghissue.Namespace + "/" + ghissue.Name
+}
+
+/*
+the body the github issue should have: spec.description followed by the ownership marker
+*/
+func issueBody(ghissue *g.GithubIssue) string {
+	marker := fmt.Sprintf(ownershipMarkerFormat, ownerKey(ghissue))
+	if ghissue.Spec.Desc == "" {
+		return marker
+	}
+	return ghissue.Spec.Desc + "\n\n" + marker
+}
+
+/*
+namespace/name of the GithubIssue object owning the issue, empty if the issue has no ownership marker
+*/
+func issueOwner(issue *tracker.Issue) string {
+	match := ownershipMarkerRegexp.FindStringSubmatch(issue.Body)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+/*
+the body of the issue without the ownership marker, i.e. what a human wrote. Only the exact "\n\n"+marker issueBody
+appends is removed, so a description ending in newlines (a YAML block scalar) compares equal to the spec. A marker
+that was reformatted on github is removed with the whitespace around it
+*/
+func bodyWithoutMarker(body string) string {
+	match := ownershipMarkerRegexp.FindStringSubmatch(body)
+	if match == nil {
+		return body
+	}
+	marker := fmt.Sprintf(ownershipMarkerFormat, match[1])
+	switch {
+	case body == marker:
+		return ""
+	case strings.HasSuffix(body, "\n\n"+marker):
+		return strings.TrimSuffix(body, "\n\n"+marker)
+	}
+	return ownershipMarkerRegexp.ReplaceAllString(body, "")
+}
+
+/*
+whether ghissue may modify the issue: issues it owns, issues nobody owns (they were named by spec.issueNumber, bound
+before the ownership marker existed, or matched by title under adoptionPolicy TitleMatch), and with adoptionPolicy
+Always issues owned by other objects
+*/
+func mayModify(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	owner := issueOwner(issue)
+	return owner == "" || owner == ownerKey(ghissue) || ghissue.Spec.AdoptionPolicy == g.AdoptionPolicyAlways
+}
+
+/*
+whether adoptionPolicy allows ghissue to take over issue found by title. An object that predates the ownership
+marker takes over its unowned issue whatever adoptionPolicy says, see predatesOwnershipMarker
+*/
+func adoptableByTitle(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	if predatesOwnershipMarker(ghissue) && issueOwner(issue) == "" {
+		return true
+	}
+	switch ghissue.Spec.AdoptionPolicy {
+	case g.AdoptionPolicyAlways:
+		return true
+	case g.AdoptionPolicyTitleMatch:
+		return issueOwner(issue) == ""
+	}
+	return false
+}
+
+/*
+ghissue was bound to its issue before the ownership marker (and status.number) existed: the status has a state but
+no number. Its issue carries no marker, so without adopting it by title once an upgrade would file a duplicate under
+the default adoptionPolicy Never. The adoption records the number and stamps the marker, so it happens only once
+*/
+func predatesOwnershipMarker(ghissue *g.GithubIssue) bool {
+	return ghissue.Status.State != "" && ghissue.Status.Number == 0 && ghissue.Spec.IssueNumber == 0
+}
+
+/*
+the issue carrying ghissue's ownership marker, e.g. after the object's status was lost (restored from a backup)
+*/
+func searchOwnedIssue(issues []*tracker.Issue, ghissue *g.GithubIssue) *tracker.Issue {
+	key := ownerKey(ghissue)
+	for _, issue := range issues {
+		if issueOwner(issue) == key {
+			return issue
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// defaultRateLimitThreshold is the remaining quota below which requests with a credential are paused until the quota resets
+	defaultRateLimitThreshold = 50
+	// minRateLimitRequeue keeps a reset that already passed (clock skew) from requeueing immediately
+	minRateLimitRequeue = time.Second
+)
+
+var rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "githubissue_github_rate_limit_remaining",
+	Help: "Remaining Github API quota per credential and rate limit resource, as reported by the last response",
+}, []string{"credentials", "resource"})
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitRemaining)
+}
+
+// rateLimiter tracks the quota of every credential from the X-RateLimit-* headers of its responses. Once the remaining
+// quota of a credential drops to the threshold (or github asks to back off), its requests are failed without being
+// sent until the quota resets, so every reconcile using the credential is paused instead of hammering the API.
+type rateLimiter struct {
+	threshold int
+
+	mu          sync.Mutex
+	pausedUntil map[string]time.Time // key is the client key of GithubClients
+}
+
+func newRateLimiter(threshold int) *rateLimiter {
+	if threshold <= 0 {
+		threshold = defaultRateLimitThreshold
+	}
+	return &rateLimiter{threshold: threshold, pausedUntil: map[string]time.Time{}}
+}
+
+// rateLimitPausedError is returned instead of sending a request while the credential is paused
+type rateLimitPausedError struct {
+	until time.Time
+}
+
+func (e *rateLimitPausedError) Error() string {
+	return fmt.Sprintf("Github API quota of the credentials is low, requests are paused until %s", e.until.Format(time.RFC3339))
+}
+
+/*
+returns a copy of httpClient whose requests are tracked under key. A nil rateLimiter returns httpClient unchanged
+*/
+func (l *rateLimiter) wrap(httpClient *http.Client, key string) *http.Client {
+	if l == nil {
+		return httpClient
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped := *httpClient
+	wrapped.Transport = &rateLimitTransport{base: base, limiter: l, key: key}
+	return &wrapped
+}
+
+func (l *rateLimiter) paused(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.pausedUntil[key]
+	if ok && time.Now().After(until) {
+		delete(l.pausedUntil, key)
+		return time.Time{}, false
+	}
+	return until, ok
+}
+
+func (l *rateLimiter) pause(key string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil[key]) {
+		l.pausedUntil[key] = until
+	}
+}
+
+/*
+records the quota reported by resp, and pauses key if it is low or github asked to back off (secondary rate limit)
+*/
+func (l *rateLimiter) observe(key string, resp *http.Response) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" &&
+		(resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			l.pause(key, time.Now().Add(time.Duration(seconds)*time.Second))
+		}
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return // not a rate limited endpoint
+	}
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	if resource == "" {
+		resource = "core"
+	}
+	credentials := key
+	if credentials == "" {
+		credentials = "default"
+	}
+	rateLimitRemaining.WithLabelValues(credentials, resource).Set(float64(remaining))
+	if remaining > l.threshold {
+		return
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		l.pause(key, time.Unix(reset, 0))
+	}
+}
+
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *rateLimiter
+	key     string
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if until, paused := t.limiter.paused(t.key); paused {
+		return nil, &rateLimitPausedError{until: until}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.limiter.observe(t.key, resp)
+	return resp, nil
+}
+
+/*
+how long to wait before retrying if err is due to the rate limit: the credential is paused, its quota is exhausted,
+or github asked to back off. ok is false for any other error
+*/
+func rateLimitRequeue(err error) (after time.Duration, ok bool) {
+	var pausedErr *rateLimitPausedError
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &pausedErr):
+		after = time.Until(pausedErr.until)
+	case errors.As(err, &rateErr):
+		after = time.Until(rateErr.Rate.Reset.Time)
+	case errors.As(err, &abuseErr):
+		after = abuseErr.GetRetryAfter()
+	default:
+		return 0, false
+	}
+	if after < minRateLimitRequeue {
+		after = minRateLimitRequeue
+	}
+	return after, true
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// syncedFields are the fields of an issue syncDirection applies to, as far as the operator writes them: assignees
+// the tracker rejected and, with labelPolicy OwnedOnly, labels nobody put in the spec are left out, so they do not
+// count as a difference between the spec and the issue
+type syncedFields struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	State       string   `json:"state"`
+	Labels      []string `json:"labels"`
+	Assignees   []string `json:"assignees"`
+}
+
+func specFields(ghissue *g.GithubIssue) syncedFields {
+	return syncedFields{
+		Title:       ghissue.Spec.Title,
+		Description: ghissue.Spec.Desc,
+		State:       desiredState(ghissue),
+		Labels:      sortedCopy(ghissue.Spec.Labels),
+		Assignees:   sortedCopy(desiredAssignees(ghissue)),
+	}
+}
+
+/*
+the ownership marker is not part of the description. With labelPolicy OwnedOnly only the labels of the spec and the
+ones the operator added (status.managedLabels) are taken, the others belong to humans. With syncDirection
+GitHubToKubernetes the operator writes nothing, the spec mirrors all labels
+*/
+func githubFields(issue *tracker.Issue, ghissue *g.GithubIssue) syncedFields {
+	labels := issue.Labels
+	if ghissue.Spec.LabelPolicy == g.LabelPolicyOwnedOnly && ghissue.Spec.SyncDirection != g.SyncDirectionGitHubToKubernetes {
+		owned := caseInsensitiveSet(append(append([]string{}, ghissue.Spec.Labels...), ghissue.Status.ManagedLabels...))
+		labels = nil
+		for _, label := range issue.Labels {
+			if owned[strings.ToLower(label)] {
+				labels = append(labels, label)
+			}
+		}
+	}
+	return syncedFields{
+		Title:       issue.Title,
+		Description: bodyWithoutMarker(issue.Body),
+		State:       issue.State,
+		Labels:      sortedCopy(labels),
+		Assignees:   sortedCopy(issue.Assignees),
+	}
+}
+
+/*
+label and user names are case insensitive, so they are hashed in lower case
+*/
+func (f syncedFields) hash() string {
+	f.Labels, f.Assignees = lowerSorted(f.Labels), lowerSorted(f.Assignees)
+	out, _ := json.Marshal(f) // can't fail for strings
+	sum := sha256.Sum256(out)
+	return hex.EncodeToString(sum[:])
+}
+
+func lowerSorted(names []string) []string {
+	lower := make([]string, 0, len(names))
+	for _, name := range names {
+		lower = append(lower, strings.ToLower(name))
+	}
+	sort.Strings(lower)
+	return lower
+}
+
+/*
+assignees the tracker rejected are never on the issue, they stay in the spec
+*/
+func (f syncedFields) applyTo(ghissue *g.GithubIssue) {
+	rejected := missingNames(ghissue.Spec.Assignees, desiredAssignees(ghissue))
+	ghissue.Spec.Title = f.Title
+	ghissue.Spec.Desc = f.Description
+	ghissue.Spec.State = f.State
+	ghissue.Spec.Labels = f.Labels
+	ghissue.Spec.Assignees = sortedCopy(append(append([]string{}, f.Assignees...), rejected...))
+}
+
+func sortedCopy(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+/*
+applies spec.syncDirection before the spec is applied to the issue. Edits made on github are copied into the spec
+(GitHubToKubernetes, or Bidirectional when only github changed since status.lastAppliedHash). Returns whether the spec
+may be applied to github, which is false for GitHubToKubernetes and for a Bidirectional conflict (recorded in the
+SyncConflict condition)
+*/
+func (r *GithubIssueReconciler) syncFromGithub(ctx context.Context, issue *tracker.Issue, ghissue *g.GithubIssue, logger logr.Logger) (pushSpec bool, err error) {
+	direction := ghissue.Spec.SyncDirection
+	if direction != g.SyncDirectionGitHubToKubernetes && direction != g.SyncDirectionBidirectional {
+		removeCondition(ghissue, g.ConditionSyncConflict)
+		return true, nil
+	}
+	onGithub := githubFields(issue, ghissue)
+	githubHash, specHash := onGithub.hash(), specFields(ghissue).hash()
+	lastApplied := ghissue.Status.LastAppliedHash
+	// without a last sync to compare to, the spec wins as it did when the issue was created or adopted
+	editedOnGithub := lastApplied != "" && githubHash != lastApplied && updatedSinceLastSync(issue, ghissue)
+	switch {
+	case githubHash == specHash:
+	case direction == g.SyncDirectionGitHubToKubernetes, editedOnGithub && specHash == lastApplied:
+		err = r.updateSpecFromGithub(ctx, issue, ghissue, onGithub)
+		if err != nil {
+			return false, err
+		}
+		logger.Info("Copied the edits made on Github into the spec", "number", issue.Number)
+		r.Recorder.Eventf(ghissue, corev1.EventTypeNormal, eventSpecUpdatedFromGithub, "Copied the edits made on issue #%d into the spec", issue.Number)
+	case editedOnGithub:
+		/* both sides changed since the last sync */
+		message := fmt.Sprintf("Both the spec and issue #%d (last updated on github at %s) changed since the last sync. "+
+			"Make them agree, or set syncDirection to the side that should win", issue.Number, issue.UpdatedAt.Format(time.RFC3339))
+		if !meta.IsStatusConditionTrue(ghissue.Status.Conditions, g.ConditionSyncConflict) {
+			r.Recorder.Eventf(ghissue, corev1.EventTypeWarning, eventSyncConflict, "Issue #%d and the spec both changed since the last sync", issue.Number)
+		}
+		setCondition(ghissue, g.ConditionSyncConflict, metav1.ConditionTrue, g.ReasonBothSidesChanged, message)
+		return false, nil
+	}
+	setCondition(ghissue, g.ConditionSyncConflict, metav1.ConditionFalse, g.ReasonNoConflict, "The spec and the issue agree on which side changed")
+	return direction == g.SyncDirectionBidirectional, nil
+}
+
+/*
+the issue was updated on github after the last sync. A copy of the issue from before the operator's last write (a
+listing lagging behind) is not taken for an edit made on github
+*/
+func updatedSinceLastSync(issue *tracker.Issue, ghissue *g.GithubIssue) bool {
+	last := ghissue.Status.LastSyncedAt
+	return last == nil || issue.UpdatedAt.Truncate(time.Second).After(last.Time)
+}
+
+/*
+records that the spec and issue agree as of now
+*/
+func setLastSynced(issue *tracker.Issue, ghissue *g.GithubIssue, fields syncedFields) {
+	ghissue.Status.LastAppliedHash = fields.hash()
+	ghissue.Status.LastSyncedAt = &metav1.Time{Time: issue.UpdatedAt}
+}
+
+/*
+r.Update returns the stored status, so the status built up during this reconcile is kept aside
+*/
+func (r *GithubIssueReconciler) updateSpecFromGithub(ctx context.Context, issue *tracker.Issue, ghissue *g.GithubIssue, onGithub syncedFields) error {
+	status := ghissue.Status.DeepCopy()
+	onGithub.applyTo(ghissue)
+	err := r.Update(ctx, ghissue)
+	if err != nil {
+		r.Log.Error(err, "r.Update() failed")
+		return err
+	}
+	ghissue.Status = *status
+	setLastSynced(issue, ghissue, onGithub)
+	return nil
+}
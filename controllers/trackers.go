@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+	"github.com/leejoebarak/githubissue-operator/tracker"
+)
+
+// IssueTrackerFunc returns the issue tracker ghissue's issue lives in, authenticated for ghissue. Failed calls of
+// the tracker are logged to logger
+type IssueTrackerFunc func(ctx context.Context, ghissue *g.GithubIssue, logger logr.Logger) (tracker.IssueTracker, error)
+
+/*
+r.IssueTracker if set, otherwise the spec.provider of ghissue with the credentials and endpoint of ghissue
+*/
+func (r *GithubIssueReconciler) trackerFor(ctx context.Context, ghissue *g.GithubIssue, logger logr.Logger) (tracker.IssueTracker, error) {
+	if r.IssueTracker != nil {
+		return r.IssueTracker(ctx, ghissue, logger)
+	}
+	target := issueTarget(ghissue)
+	switch ghissue.Spec.Provider {
+	case g.ProviderGitLab:
+		httpClient, baseURL, creds, err := r.Clients.tokenClientFor(ctx, r.Client, target, tracker.DefaultGitLabURL)
+		if err != nil {
+			return nil, err
+		}
+		return tracker.NewGitLab(httpClient, baseURL, creds.token, logger), nil
+	case g.ProviderGitea:
+		httpClient, baseURL, creds, err := r.Clients.tokenClientFor(ctx, r.Client, target, "")
+		if err != nil {
+			return nil, err
+		}
+		return tracker.NewGitea(httpClient, baseURL, creds.token, logger), nil
+	case g.ProviderJira:
+		httpClient, baseURL, creds, err := r.Clients.tokenClientFor(ctx, r.Client, target, "")
+		if err != nil {
+			return nil, err
+		}
+		return tracker.NewJira(httpClient, baseURL, creds.username, creds.token, jiraOptions(ghissue.Spec.Jira), logger), nil
+	}
+	githubClient, err := r.Clients.clientFor(ctx, r.Client, target)
+	if err != nil {
+		return nil, err
+	}
+	return tracker.NewGitHub(githubClient, logger), nil
+}
+
+func jiraOptions(spec *g.JiraSpec) tracker.JiraOptions {
+	if spec == nil {
+		return tracker.JiraOptions{}
+	}
+	return tracker.JiraOptions{
+		Server:           spec.Deployment == g.JiraDeploymentServer,
+		IssueType:        spec.IssueType,
+		CloseTransition:  spec.CloseTransition,
+		ReopenTransition: spec.ReopenTransition,
+	}
+}
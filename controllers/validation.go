@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	g "github.com/leejoebarak/githubissue-operator/api/v1alpha1"
+)
+
+// jiraProjectKeyRegexp matches the keys Jira allows for projects
+var jiraProjectKeyRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]+$`)
+
+/*
+a specError if ghissue asks for what its provider can not do. Checked before touching the issue, as retrying does not
+help until the spec changes
+*/
+func validateSpec(ghissue *g.GithubIssue) error {
+	err := validateRepo(ghissue.Spec.Provider, ghissue.Spec.Repo)
+	if err != nil {
+		return err
+	}
+	if ghissue.Spec.BaseURL == "" && needsBaseURL(ghissue.Spec.Provider) {
+		return invalidSpec("spec.baseURL is required with provider %s", ghissue.Spec.Provider)
+	}
+	if ghissue.Spec.DeletionPolicy == g.DeletionPolicyLock && !canLock(ghissue.Spec.Provider) {
+		return invalidSpec("Provider %s can not lock issues, use deletionPolicy Close or CommentAndClose", ghissue.Spec.Provider)
+	}
+	if ghissue.Spec.MilestoneRef != nil && !isGitHub(ghissue.Spec.Provider) {
+		return invalidSpec("Provider %s does not support milestoneRef, GithubMilestones only exist on GitHub", ghissue.Spec.Provider)
+	}
+	if ghissue.Spec.StateReason != "" && !isGitHub(ghissue.Spec.Provider) {
+		return invalidSpec("Provider %s does not support stateReason, leave it empty", ghissue.Spec.Provider)
+	}
+	return nil
+}
+
+/*
+the shape of spec.repo depends on the provider: owner/repo on GitHub and Gitea, the path of a project in any number of
+groups on GitLab, a project key on Jira
+*/
+func validateRepo(provider g.Provider, repo string) error {
+	switch provider {
+	case g.ProviderJira:
+		if !jiraProjectKeyRegexp.MatchString(repo) {
+			return invalidSpec("Provider Jira takes a project key as repo (e.g. PROJ), not %q", repo)
+		}
+		return nil
+	case g.ProviderGitLab:
+		split := strings.Split(repo, "/")
+		for _, segment := range split {
+			if segment == "" {
+				split = nil
+			}
+		}
+		if len(split) < 2 {
+			return invalidSpec("Provider GitLab takes the path of a project as repo (e.g. group/subgroup/project), not %q", repo)
+		}
+		return nil
+	}
+	_, _, err := splitOwnerRepo(repo)
+	return err
+}
+
+/*
+spec.provider defaults to GitHub, but objects created before it existed have none
+*/
+func isGitHub(provider g.Provider) bool {
+	return provider == "" || provider == g.ProviderGitHub
+}
+
+/*
+whether the provider has no public instance to default spec.baseURL to
+*/
+func needsBaseURL(provider g.Provider) bool {
+	return provider == g.ProviderGitea || provider == g.ProviderJira
+}
+
+/*
+whether the tracker of the provider implements Lock
+*/
+func canLock(provider g.Provider) bool {
+	return provider != g.ProviderGitea && provider != g.ProviderJira
+}
+
+func invalidSpec(format string, args ...interface{}) *specError {
+	return &specError{reason: g.ReasonInvalidSpec, message: fmt.Sprintf(format, args...)}
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -26,6 +30,7 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -50,14 +55,43 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var listPageSize int
+	var listMaxIssues int
+	var cacheInterval time.Duration
+	var defaultCredentialsSecret string
+	var githubBaseURL string
+	var githubUploadURL string
+	var githubCABundle string
+	var rateLimitThreshold int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&listPageSize, "github-list-page-size", 100, "The number of issues requested per page when listing a Github repository (max 100).")
+	flag.IntVar(&listMaxIssues, "github-list-max-issues", 10000,
+		"The maximum number of issues listed per Github repository when searching for an issue by title. "+
+			"Objects whose issue may lie beyond this bound get the IssueListTruncated condition instead of a new issue.")
+	flag.DurationVar(&cacheInterval, "github-cache-interval", time.Minute,
+		"How often the issue list of a Github repository, shared by all GithubIssue objects of that repository, is refreshed.")
+	flag.StringVar(&defaultCredentialsSecret, "default-credentials-secret", "",
+		"The namespace/name of the Secret holding the Github credentials (a token, or a Github App's appID, privateKey "+
+			"and optional installationID) used by GithubIssue objects without spec.credentialsRef. "+
+			"If empty, the TOKEN environment variable is used.")
+	flag.StringVar(&githubBaseURL, "github-base-url", "",
+		"The API URL of the Github Enterprise Server to use by default, e.g. https://github.example.com/api/v3/. "+
+			"If empty, github.com is used. GithubIssue objects can override it with spec.baseURL.")
+	flag.StringVar(&githubUploadURL, "github-upload-url", "", "The upload API URL of the Github Enterprise Server, derived from --github-base-url if empty.")
+	flag.StringVar(&githubCABundle, "github-ca-bundle", "", "The path of a PEM file with CA certificates to trust when talking to Github Enterprise Servers.")
+	flag.IntVar(&rateLimitThreshold, "github-rate-limit-threshold", 50,
+		"The remaining Github API quota of a credential below which its requests are paused until the quota resets. "+
+			"Objects using a paused credential are requeued for the reset instead of failing.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,6 +99,24 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	defaultSecret := types.NamespacedName{}
+	if defaultCredentialsSecret != "" {
+		split := strings.SplitN(defaultCredentialsSecret, "/", 2)
+		if len(split) != 2 {
+			setupLog.Error(nil, "--default-credentials-secret must be namespace/name", "value", defaultCredentialsSecret)
+			os.Exit(1)
+		}
+		defaultSecret = types.NamespacedName{Namespace: split[0], Name: split[1]}
+	}
+	defaultEndpoint := controllers.GithubEndpoint{BaseURL: githubBaseURL, UploadURL: githubUploadURL}
+	if githubCABundle != "" {
+		caBundle, err := ioutil.ReadFile(githubCABundle)
+		if err != nil {
+			setupLog.Error(err, "unable to read --github-ca-bundle")
+			os.Exit(1)
+		}
+		defaultEndpoint.CABundle = caBundle
+	}
 	timeDuration := time.Duration(time.Second * 60)
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{ //todo handle resync
 		Scheme:                 scheme,
@@ -80,14 +132,56 @@ func main() {
 		os.Exit(1)
 	}
 
+	/* one cache of clients for all controllers, so every credential has a single client and a single rate limit */
+	clients := controllers.NewGithubClients(defaultSecret, defaultEndpoint, rateLimitThreshold)
 	if err = (&controllers.GithubIssueReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("GithubIssue"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubIssue"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("githubissue-controller"),
+
+		ListPageSize:  listPageSize,
+		ListMaxIssues: listMaxIssues,
+		CacheInterval: cacheInterval,
+
+		Clients: clients,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GithubIssue")
 		os.Exit(1)
 	}
+	if err = (&controllers.GithubIssueCommentReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubIssueComment"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("githubissuecomment-controller"),
+
+		Clients: clients,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GithubIssueComment")
+		os.Exit(1)
+	}
+	if err = (&controllers.GithubLabelReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubLabel"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("githublabel-controller"),
+
+		Clients: clients,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GithubLabel")
+		os.Exit(1)
+	}
+	if err = (&controllers.GithubMilestoneReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("GithubMilestone"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("githubmilestone-controller"),
+
+		Clients: clients,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GithubMilestone")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -106,3 +200,55 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+/*
+manager import --repo owner/repo [--state open] [--output-dir dir]: writes a GithubIssue manifest per issue of the
+repository to stdout (or a file per issue to --output-dir), ready to be committed and applied to adopt the issues
+*/
+func runImport(args []string) int {
+	var opts controllers.ImportOptions
+	var outputDir string
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&opts.Repo, "repo", "", "The owner/repo of the Github repository to import.")
+	fs.StringVar(&opts.State, "state", "open", "The state of the issues to import: open, closed or all.")
+	fs.StringVar(&opts.Namespace, "namespace", "", "The namespace of the generated GithubIssue objects. Omitted if empty.")
+	fs.StringVar(&outputDir, "output-dir", "", "The directory to write a file per issue to. If empty, all manifests are written to stdout.")
+	fs.IntVar(&opts.PageSize, "github-list-page-size", 100, "The number of issues requested per page (max 100).")
+	fs.IntVar(&opts.MaxIssues, "github-list-max-issues", 10000, "The maximum number of issues imported.")
+	fs.StringVar(&opts.Endpoint.BaseURL, "github-base-url", "", "The API URL of the Github Enterprise Server to import from, written into spec.baseURL of every manifest. If empty, github.com is used.")
+	fs.StringVar(&opts.Endpoint.UploadURL, "github-upload-url", "", "The upload API URL of the Github Enterprise Server, written into spec.uploadURL of every manifest. Derived from --github-base-url if empty.")
+	zapOpts := zap.Options{Development: true}
+	zapOpts.BindFlags(fs)
+	_ = fs.Parse(args)
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts), zap.WriteTo(os.Stderr)))
+	logger := ctrl.Log.WithName("import")
+
+	if opts.Repo == "" {
+		fmt.Fprintln(os.Stderr, "--repo is required")
+		fs.Usage()
+		return 2
+	}
+	if opts.State != "open" && opts.State != "closed" && opts.State != "all" {
+		fmt.Fprintln(os.Stderr, "--state must be open, closed or all")
+		return 2
+	}
+	opts.Token = os.Getenv("TOKEN")
+	manifests, truncated, err := controllers.ImportIssues(context.Background(), opts, logger)
+	if err != nil {
+		logger.Error(err, "unable to list the issues", "repo", opts.Repo)
+		return 1
+	}
+	if truncated {
+		logger.Info("Repository has more issues than --github-list-max-issues, only the first ones were imported", "imported", len(manifests))
+	}
+	if outputDir != "" {
+		err = controllers.WriteManifestsToDir(outputDir, manifests)
+	} else {
+		err = controllers.WriteManifests(os.Stdout, manifests)
+	}
+	if err != nil {
+		logger.Error(err, "unable to write the manifests")
+		return 1
+	}
+	return 0
+}
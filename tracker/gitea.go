@@ -0,0 +1,360 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// defaultGiteaLabelColor is given to labels Gitea does not know yet, github's default label color
+const defaultGiteaLabelColor = "#ededed"
+
+// Gitea is the IssueTracker of Gitea and Forgejo instances, whose APIs are the same. repo is owner/repo.
+// Milestones and locking are not supported
+type Gitea struct {
+	rest   *restClient
+	logger logr.Logger
+}
+
+var _ IssueTracker = &Gitea{}
+
+/*
+baseURL is the API URL, e.g. https://gitea.example.com/api/v1/. token is an access token of a user
+*/
+func NewGitea(httpClient *http.Client, baseURL, token string, logger logr.Logger) *Gitea {
+	header := http.Header{}
+	header.Set("Authorization", "token "+token)
+	return &Gitea{rest: newRESTClient(httpClient, baseURL, header), logger: logger}
+}
+
+// giteaIssue is an issue as the Gitea API returns it
+type giteaIssue struct {
+	Number    int          `json:"number"`
+	Title     string       `json:"title"`
+	Body      string       `json:"body"`
+	State     string       `json:"state"`
+	Labels    []giteaLabel `json:"labels"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	IsLocked    bool      `json:"is_locked"`
+	HTMLURL     string    `json:"html_url"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+type giteaLabel struct {
+	ID    int64  `json:"id,omitempty"`
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// giteaIssueRequest is the body of an issue creation or edit
+type giteaIssueRequest struct {
+	Title     *string   `json:"title,omitempty"`
+	Body      *string   `json:"body,omitempty"`
+	State     string    `json:"state,omitempty"`
+	Labels    []int64   `json:"labels,omitempty"` // only read on creation
+	Assignees *[]string `json:"assignees,omitempty"`
+}
+
+func (t *Gitea) Get(ctx context.Context, repo string, number int) (*Issue, error) {
+	issue, err := t.getIssue(ctx, repo, number)
+	if hasStatus(err, http.StatusNotFound, http.StatusGone) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, t.failed("Get()", err, "Reading gitea issue failed")
+	}
+	return issue.toIssue(), nil
+}
+
+func (t *Gitea) getIssue(ctx context.Context, repo string, number int) (*giteaIssue, error) {
+	issue := &giteaIssue{}
+	_, err := t.rest.do(ctx, "GET", t.issuePath(repo, number), nil, nil, issue)
+	return issue, err
+}
+
+/*
+pages through the issues of the repository (following the Link header, as Gitea caps the page size), newest first,
+up to opts.MaxIssues issues. Gitea has no conditional listing, opts.ETag is ignored
+*/
+func (t *Gitea) List(ctx context.Context, repo string, opts ListOptions) (*IssueList, error) {
+	query := url.Values{}
+	query.Set("type", "issues")
+	query.Set("state", "all")
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.PageSize > 0 {
+		query.Set("limit", strconv.Itoa(opts.PageSize))
+	}
+	list := &IssueList{}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var issues []giteaIssue
+		resp, err := t.rest.do(ctx, "GET", "repos/"+repo+"/issues", query, nil, &issues)
+		if err != nil {
+			return nil, t.failed("List()", err, "Reading the list of issues from gitea repo failed")
+		}
+		for i := range issues {
+			list.Issues = append(list.Issues, issues[i].toIssue())
+		}
+		next := len(issues) > 0 && hasNextPage(resp)
+		if opts.MaxIssues > 0 && len(list.Issues) >= opts.MaxIssues {
+			list.Truncated = next || len(list.Issues) > opts.MaxIssues
+			list.Issues = list.Issues[:opts.MaxIssues]
+			return list, nil
+		}
+		if !next {
+			return list, nil
+		}
+	}
+}
+
+/*
+labels Gitea does not know yet are created, as github does
+*/
+func (t *Gitea) Create(ctx context.Context, repo string, issue IssueRequest) (*Issue, error) {
+	if issue.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	labelIDs, err := t.labelIDs(ctx, repo, issue.Labels)
+	if err != nil {
+		return nil, t.failed("Create()", err, "Resolving gitea labels failed")
+	}
+	body := &giteaIssueRequest{Title: &issue.Title, Body: &issue.Body, Labels: labelIDs}
+	if len(issue.Assignees) > 0 {
+		body.Assignees = &issue.Assignees
+	}
+	created := &giteaIssue{}
+	_, err = t.rest.do(ctx, "POST", "repos/"+repo+"/issues", nil, body, created)
+	if err != nil {
+		return nil, t.failed("Create()", err, "Creation of gitea issue failed")
+	}
+	return created.toIssue(), nil
+}
+
+func (t *Gitea) Update(ctx context.Context, repo string, number int, update IssueUpdate) (*Issue, error) {
+	if update.Milestone != nil && *update.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	if update.Title == nil && update.Body == nil {
+		return t.Get(ctx, repo, number)
+	}
+	return t.edit(ctx, repo, number, &giteaIssueRequest{Title: update.Title, Body: update.Body}, "Update()")
+}
+
+/*
+Gitea has no close reason, reason is ignored
+*/
+func (t *Gitea) Close(ctx context.Context, repo string, number int, reason string) (*Issue, error) {
+	return t.edit(ctx, repo, number, &giteaIssueRequest{State: StateClosed}, "Close()")
+}
+
+func (t *Gitea) Reopen(ctx context.Context, repo string, number int) (*Issue, error) {
+	return t.edit(ctx, repo, number, &giteaIssueRequest{State: StateOpen}, "Reopen()")
+}
+
+/*
+the Gitea API can not lock issues
+*/
+func (t *Gitea) Lock(ctx context.Context, repo string, number int) error {
+	return fmt.Errorf("locking issues: %w", ErrNotSupported)
+}
+
+func (t *Gitea) edit(ctx context.Context, repo string, number int, body *giteaIssueRequest, method string) (*Issue, error) {
+	issue := &giteaIssue{}
+	_, err := t.rest.do(ctx, "PATCH", t.issuePath(repo, number), nil, body, issue)
+	if err != nil {
+		return nil, t.failed(method, err, "Updating gitea issue failed")
+	}
+	return issue.toIssue(), nil
+}
+
+func (t *Gitea) Comment(ctx context.Context, repo string, number int, body string) error {
+	_, err := t.rest.do(ctx, "POST", t.issuePath(repo, number)+"/comments", nil, map[string]string{"body": body}, nil)
+	if err != nil {
+		return t.failed("Comment()", err, "Commenting on gitea issue failed")
+	}
+	return nil
+}
+
+/*
+labels Gitea does not know yet are created, as github does
+*/
+func (t *Gitea) SetLabels(ctx context.Context, repo string, number int, labels []string) error {
+	ids, err := t.labelIDs(ctx, repo, labels)
+	if err != nil {
+		return t.failed("SetLabels()", err, "Resolving gitea labels failed")
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+	_, err = t.rest.do(ctx, "PUT", t.issuePath(repo, number)+"/labels", nil, map[string][]int64{"labels": ids}, nil)
+	if err != nil {
+		return t.failed("SetLabels()", err, "Updating labels of gitea issue failed")
+	}
+	return nil
+}
+
+func (t *Gitea) ListLabels(ctx context.Context, repo string) ([]string, error) {
+	labels, err := t.listLabels(ctx, repo)
+	if err != nil {
+		return nil, t.failed("ListLabels()", err, "Reading the labels of gitea repo failed")
+	}
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return names, nil
+}
+
+func (t *Gitea) listLabels(ctx context.Context, repo string) ([]giteaLabel, error) {
+	var all []giteaLabel
+	query := url.Values{}
+	for page := 1; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		var labels []giteaLabel
+		resp, err := t.rest.do(ctx, "GET", "repos/"+repo+"/labels", query, nil, &labels)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, labels...)
+		if len(labels) == 0 || !hasNextPage(resp) {
+			return all, nil
+		}
+	}
+}
+
+/*
+Gitea takes label ids instead of names. Label names are case insensitive, like on github
+*/
+func (t *Gitea) labelIDs(ctx context.Context, repo string, names []string) ([]int64, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	labels, err := t.listLabels(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]int64{}
+	for _, label := range labels {
+		byName[strings.ToLower(label.Name)] = label.ID
+	}
+	ids := make([]int64, 0, len(names))
+	for _, name := range names {
+		id, ok := byName[strings.ToLower(name)]
+		if !ok {
+			created := &giteaLabel{}
+			_, err = t.rest.do(ctx, "POST", "repos/"+repo+"/labels", nil, &giteaLabel{Name: name, Color: defaultGiteaLabelColor}, created)
+			if err != nil {
+				return nil, err
+			}
+			id = created.ID
+			byName[strings.ToLower(name)] = id
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+/*
+only the users listed as assignees of the repository (its owner and collaborators) can be assigned
+*/
+func (t *Gitea) ValidateAssignees(ctx context.Context, repo string, logins []string) (valid, rejected []string, err error) {
+	if len(logins) == 0 {
+		return nil, nil, nil
+	}
+	var users []struct {
+		Login string `json:"login"`
+	}
+	_, err = t.rest.do(ctx, "GET", "repos/"+repo+"/assignees", nil, nil, &users)
+	if err != nil {
+		return nil, nil, t.failed("ValidateAssignees()", err, "Reading the assignees of gitea repo failed")
+	}
+	assignable := map[string]bool{}
+	for _, user := range users {
+		assignable[strings.ToLower(user.Login)] = true
+	}
+	for _, login := range logins {
+		if assignable[strings.ToLower(login)] {
+			valid = append(valid, login)
+		} else {
+			rejected = append(rejected, login)
+		}
+	}
+	return valid, rejected, nil
+}
+
+/*
+Gitea only sets the assignees as a whole
+*/
+func (t *Gitea) AddAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	return t.changeAssignees(ctx, repo, number, logins, nil, "AddAssignees()")
+}
+
+func (t *Gitea) RemoveAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	return t.changeAssignees(ctx, repo, number, nil, logins, "RemoveAssignees()")
+}
+
+func (t *Gitea) changeAssignees(ctx context.Context, repo string, number int, add, remove []string, method string) error {
+	issue, err := t.getIssue(ctx, repo, number)
+	if err != nil {
+		return t.failed(method, err, "Reading gitea issue failed")
+	}
+	removed := map[string]bool{}
+	for _, login := range remove {
+		removed[strings.ToLower(login)] = true
+	}
+	logins := []string{}
+	for _, user := range issue.Assignees {
+		if !removed[strings.ToLower(user.Login)] {
+			logins = append(logins, user.Login)
+		}
+	}
+	logins = append(logins, add...)
+	_, err = t.edit(ctx, repo, number, &giteaIssueRequest{Assignees: &logins}, method)
+	return err
+}
+
+func (t *Gitea) issuePath(repo string, number int) string {
+	return fmt.Sprintf("repos/%s/issues/%d", repo, number)
+}
+
+func (t *Gitea) failed(method string, err error, msg string) error {
+	t.logger.WithName(method).Error(err, msg)
+	return err
+}
+
+func (issue *giteaIssue) toIssue() *Issue {
+	converted := &Issue{
+		Number:      issue.Number,
+		Title:       issue.Title,
+		Body:        issue.Body,
+		State:       StateOpen,
+		Locked:      issue.IsLocked,
+		HTMLURL:     issue.HTMLURL,
+		UpdatedAt:   issue.UpdatedAt,
+		PullRequest: issue.PullRequest != nil,
+	}
+	if issue.State == StateClosed {
+		converted.State = StateClosed
+	}
+	for _, label := range issue.Labels {
+		converted.Labels = append(converted.Labels, label.Name)
+	}
+	for _, user := range issue.Assignees {
+		converted.Assignees = append(converted.Assignees, user.Login)
+	}
+	return converted
+}
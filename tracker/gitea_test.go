@@ -0,0 +1,181 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Gitea", func() {
+	const repoPath = "/api/v1/repos/octo/hello"
+	var (
+		ctx = context.Background()
+		api *fakeAPI
+		t   *Gitea
+	)
+
+	BeforeEach(func() {
+		api = newFakeAPI()
+		t = NewGitea(nil, api.server.URL+"/api/v1/", "gitea-token", logr.Discard())
+		api.reply("GET", repoPath+"/labels", http.StatusOK, `[{"id": 1, "name": "Bug"}, {"id": 2, "name": "feature"}]`)
+		api.reply("GET", repoPath+"/assignees", http.StatusOK, `[{"login": "octocat"}, {"login": "Hubot"}]`)
+	})
+
+	AfterEach(func() {
+		api.close()
+	})
+
+	giteaIssueJSON := func(number int, state string, assignees ...string) string {
+		users := ""
+		for i, login := range assignees {
+			if i > 0 {
+				users += ","
+			}
+			users += fmt.Sprintf(`{"login": %q}`, login)
+		}
+		return fmt.Sprintf(`{"number": %d, "title": "issue %d", "body": "text", "state": %q, "labels": [{"id": 1, "name": "Bug"}],
+			"assignees": [%s], "html_url": "https://gitea.example.com/octo/hello/issues/%d", "updated_at": "2021-06-01T10:00:00Z"}`,
+			number, number, state, users, number)
+	}
+
+	Context("Get", func() {
+		It("converts the issue", func() {
+			api.reply("GET", repoPath+"/issues/1", http.StatusOK, giteaIssueJSON(1, "closed", "octocat"))
+
+			issue, err := t.Get(ctx, "octo/hello", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(1))
+			Expect(issue.Title).To(Equal("issue 1"))
+			Expect(issue.Body).To(Equal("text"))
+			Expect(issue.State).To(Equal(StateClosed))
+			Expect(issue.Labels).To(Equal([]string{"Bug"}))
+			Expect(issue.Assignees).To(Equal([]string{"octocat"}))
+			Expect(issue.HTMLURL).To(Equal("https://gitea.example.com/octo/hello/issues/1"))
+			Expect(issue.PullRequest).To(BeFalse())
+			Expect(api.lastRequest("GET", repoPath+"/issues/1").Header.Get("Authorization")).To(Equal("token gitea-token"))
+		})
+
+		It("returns nil for an issue that does not exist", func() {
+			issue, err := t.Get(ctx, "octo/hello", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue).To(BeNil())
+		})
+	})
+
+	Context("List", func() {
+		It("follows the Link header up to MaxIssues", func() {
+			api.handle("GET", repoPath+"/issues", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") == "1" {
+					w.Header().Set("Link", `<`+api.server.URL+repoPath+`/issues?page=2>; rel="next"`)
+					fmt.Fprintf(w, "[%s, %s]", giteaIssueJSON(4, "open"), giteaIssueJSON(3, "open"))
+					return
+				}
+				w.Header().Set("Link", `<`+api.server.URL+repoPath+`/issues?page=3>; rel="next"`)
+				fmt.Fprintf(w, "[%s, %s]", giteaIssueJSON(2, "open"), giteaIssueJSON(1, "open"))
+			})
+
+			list, err := t.List(ctx, "octo/hello", ListOptions{PageSize: 2, MaxIssues: 3})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Issues).To(HaveLen(3))
+			Expect(list.Truncated).To(BeTrue())
+			query := api.lastRequest("GET", repoPath+"/issues").Query
+			Expect(query.Get("type")).To(Equal("issues"))
+			Expect(query.Get("state")).To(Equal("all"))
+			Expect(query.Get("limit")).To(Equal("2"))
+		})
+
+		It("stops at an empty page", func() {
+			api.handle("GET", repoPath+"/issues", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Link", `<`+api.server.URL+repoPath+`/issues?page=9>; rel="next"`)
+				if r.URL.Query().Get("page") == "1" {
+					fmt.Fprintf(w, "[%s]", giteaIssueJSON(1, "open"))
+					return
+				}
+				fmt.Fprintf(w, "[]")
+			})
+
+			list, err := t.List(ctx, "octo/hello", ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Issues).To(HaveLen(1))
+			Expect(list.Truncated).To(BeFalse())
+			Expect(api.requestCount("GET", repoPath+"/issues")).To(Equal(2))
+		})
+	})
+
+	Context("labels", func() {
+		BeforeEach(func() {
+			api.reply("POST", repoPath+"/labels", http.StatusCreated, `{"id": 3, "name": "ui"}`)
+		})
+
+		It("creates issues with the ids of the labels, creating unknown labels", func() {
+			api.reply("POST", repoPath+"/issues", http.StatusCreated, giteaIssueJSON(5, "open"))
+
+			issue, err := t.Create(ctx, "octo/hello", IssueRequest{Title: "new", Body: "text", Labels: []string{"bug", "ui"}, Assignees: []string{"octocat"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(5))
+			body := api.lastRequest("POST", repoPath+"/issues").json()
+			Expect(body).To(HaveKeyWithValue("labels", ConsistOf(BeEquivalentTo(1), BeEquivalentTo(3))))
+			Expect(body).To(HaveKeyWithValue("assignees", ConsistOf("octocat")))
+			Expect(api.lastRequest("POST", repoPath+"/labels").json()).To(Equal(map[string]interface{}{"name": "ui", "color": defaultGiteaLabelColor}))
+		})
+
+		It("replaces the labels of an issue", func() {
+			api.reply("PUT", repoPath+"/issues/1/labels", http.StatusOK, `[]`)
+
+			Expect(t.SetLabels(ctx, "octo/hello", 1, []string{"FEATURE"})).To(Succeed())
+			Expect(api.lastRequest("PUT", repoPath+"/issues/1/labels").json()).To(HaveKeyWithValue("labels", ConsistOf(BeEquivalentTo(2))))
+			Expect(api.requestCount("POST", repoPath+"/labels")).To(BeZero())
+
+			Expect(t.SetLabels(ctx, "octo/hello", 1, nil)).To(Succeed())
+			Expect(api.lastRequest("PUT", repoPath+"/issues/1/labels").json()).To(HaveKeyWithValue("labels", BeEmpty()))
+		})
+
+		It("lists the names of the labels", func() {
+			labels, err := t.ListLabels(ctx, "octo/hello")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(labels).To(Equal([]string{"Bug", "feature"}))
+		})
+	})
+
+	Context("editing issues", func() {
+		BeforeEach(func() {
+			api.reply("GET", repoPath+"/issues/1", http.StatusOK, giteaIssueJSON(1, "open", "octocat", "Hubot"))
+			api.reply("PATCH", repoPath+"/issues/1", http.StatusCreated, giteaIssueJSON(1, "open"))
+		})
+
+		It("closes by state", func() {
+			_, err := t.Close(ctx, "octo/hello", 1, "not_planned")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api.lastRequest("PATCH", repoPath+"/issues/1").json()).To(Equal(map[string]interface{}{"state": "closed"}))
+		})
+
+		It("keeps the other assignees when removing one", func() {
+			Expect(t.RemoveAssignees(ctx, "octo/hello", 1, []string{"hubot"})).To(Succeed())
+			Expect(api.lastRequest("PATCH", repoPath+"/issues/1").json()).To(Equal(map[string]interface{}{"assignees": []interface{}{"octocat"}}))
+		})
+
+		It("does not support milestones", func() {
+			milestone := 1
+			_, err := t.Update(ctx, "octo/hello", 1, IssueUpdate{Milestone: &milestone})
+			Expect(errors.Is(err, ErrNotSupported)).To(BeTrue())
+			Expect(api.requestCount("PATCH", repoPath+"/issues/1")).To(BeZero())
+		})
+
+		It("does not support locking", func() {
+			Expect(errors.Is(t.Lock(ctx, "octo/hello", 1), ErrNotSupported)).To(BeTrue())
+			Expect(api.requests).To(BeEmpty())
+		})
+	})
+
+	It("accepts the assignees of the repository, ignoring case", func() {
+		valid, rejected, err := t.ValidateAssignees(ctx, "octo/hello", []string{"hubot", "stranger"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(valid).To(Equal([]string{"hubot"}))
+		Expect(rejected).To(Equal([]string{"stranger"}))
+	})
+})
@@ -0,0 +1,294 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+)
+
+// GitHub is the IssueTracker of github.com and Github Enterprise Servers
+type GitHub struct {
+	client *github.Client
+	logger logr.Logger
+}
+
+var _ IssueTracker = &GitHub{}
+
+/*
+client is authenticated and pointed at the Github instance by the caller. Failed calls are logged to logger
+*/
+func NewGitHub(client *github.Client, logger logr.Logger) *GitHub {
+	return &GitHub{client: client, logger: logger}
+}
+
+func (t *GitHub) Get(ctx context.Context, repo string, number int) (*Issue, error) {
+	owner, name := splitRepo(repo)
+	issue, resp, err := t.client.Issues.Get(ctx, owner, name, number)
+	if resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) {
+		return nil, nil
+	}
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		return nil, t.failed("Get()", err, resp, "Reading github issue failed")
+	}
+	return fromGithubIssue(issue), nil
+}
+
+/*
+pages through the issues of the repository (following resp.NextPage), up to opts.MaxIssues issues. opts.ETag is sent
+with the request for the first page; if Github answers 304 Not Modified (which does not count against the rate
+limit) nothing changed and no issues are returned
+*/
+func (t *GitHub) List(ctx context.Context, repo string, opts ListOptions) (*IssueList, error) {
+	owner, name := splitRepo(repo)
+	query := url.Values{}
+	query.Set("state", "all")
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	if !opts.Since.IsZero() {
+		query.Set("since", opts.Since.UTC().Format(time.RFC3339))
+		/* most recently updated first, so an update of any issue changes the first page and its ETag */
+		query.Set("sort", "updated")
+	}
+	if opts.PageSize > 0 {
+		query.Set("per_page", strconv.Itoa(opts.PageSize))
+	}
+	list := &IssueList{}
+	page := 1
+	for {
+		query.Set("page", strconv.Itoa(page))
+		req, err := t.client.NewRequest("GET", fmt.Sprintf("repos/%v/%v/issues?%v", owner, name, query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		if page == 1 && opts.ETag != "" {
+			req.Header.Set("If-None-Match", opts.ETag)
+		}
+		var issues []*github.Issue
+		resp, err := t.client.Do(ctx, req, &issues)
+		if page == 1 && resp != nil && resp.StatusCode == http.StatusNotModified {
+			return &IssueList{ETag: opts.ETag, NotModified: true}, nil
+		}
+		if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+			return nil, t.failed("List()", err, resp, "Reading the list of issues from github repo failed")
+		}
+		if page == 1 {
+			list.ETag = resp.Header.Get("ETag")
+		}
+		for _, issue := range issues {
+			list.Issues = append(list.Issues, fromGithubIssue(issue))
+		}
+		if opts.MaxIssues > 0 && len(list.Issues) >= opts.MaxIssues {
+			list.Truncated = resp.NextPage != 0 || len(list.Issues) > opts.MaxIssues
+			list.Issues = list.Issues[:opts.MaxIssues]
+			return list, nil
+		}
+		if resp.NextPage == 0 {
+			return list, nil
+		}
+		page = resp.NextPage
+	}
+}
+
+func (t *GitHub) Create(ctx context.Context, repo string, issue IssueRequest) (*Issue, error) {
+	owner, name := splitRepo(repo)
+	issueReq := &github.IssueRequest{
+		Title: github.String(issue.Title),
+		Body:  github.String(issue.Body),
+		State: github.String(StateOpen),
+	}
+	if len(issue.Labels) > 0 {
+		issueReq.Labels = &issue.Labels
+	}
+	if len(issue.Assignees) > 0 {
+		issueReq.Assignees = &issue.Assignees
+	}
+	if issue.Milestone != 0 {
+		issueReq.Milestone = &issue.Milestone
+	}
+	created, resp, err := t.client.Issues.Create(ctx, owner, name, issueReq)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
+		return nil, t.failed("Create()", err, resp, "Creation of github issue failed")
+	}
+	return fromGithubIssue(created), nil
+}
+
+// issueEditRequest is the body of an issue edit. go-github's IssueRequest can neither remove the milestone (it omits
+// a nil milestone) nor send state_reason
+type issueEditRequest struct {
+	Title       *string         `json:"title,omitempty"`
+	Body        *string         `json:"body,omitempty"`
+	State       string          `json:"state,omitempty"`
+	StateReason string          `json:"state_reason,omitempty"`
+	Milestone   json.RawMessage `json:"milestone,omitempty"` // null removes the milestone
+}
+
+func (t *GitHub) Update(ctx context.Context, repo string, number int, update IssueUpdate) (*Issue, error) {
+	body := &issueEditRequest{Title: update.Title, Body: update.Body}
+	if update.Milestone != nil {
+		body.Milestone = json.RawMessage("null")
+		if *update.Milestone != 0 {
+			body.Milestone = json.RawMessage(strconv.Itoa(*update.Milestone))
+		}
+	}
+	return t.edit(ctx, repo, number, body, "Update()")
+}
+
+func (t *GitHub) Close(ctx context.Context, repo string, number int, reason string) (*Issue, error) {
+	return t.edit(ctx, repo, number, &issueEditRequest{State: StateClosed, StateReason: reason}, "Close()")
+}
+
+func (t *GitHub) Reopen(ctx context.Context, repo string, number int) (*Issue, error) {
+	return t.edit(ctx, repo, number, &issueEditRequest{State: StateOpen}, "Reopen()")
+}
+
+func (t *GitHub) edit(ctx context.Context, repo string, number int, body *issueEditRequest, method string) (*Issue, error) {
+	owner, name := splitRepo(repo)
+	req, err := t.client.NewRequest("PATCH", fmt.Sprintf("repos/%v/%v/issues/%d", owner, name, number), body)
+	if err != nil {
+		return nil, err
+	}
+	issue := new(github.Issue)
+	resp, err := t.client.Do(ctx, req, issue)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		return nil, t.failed(method, err, resp, "Updating github issue failed")
+	}
+	return fromGithubIssue(issue), nil
+}
+
+func (t *GitHub) Lock(ctx context.Context, repo string, number int) error {
+	owner, name := splitRepo(repo)
+	resp, err := t.client.Issues.Lock(ctx, owner, name, number, &github.LockIssueOptions{LockReason: "resolved"})
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusNoContent) {
+		return t.failed("Lock()", err, resp, "Locking github issue failed")
+	}
+	return nil
+}
+
+func (t *GitHub) Comment(ctx context.Context, repo string, number int, body string) error {
+	owner, name := splitRepo(repo)
+	_, resp, err := t.client.Issues.CreateComment(ctx, owner, name, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
+		return t.failed("Comment()", err, resp, "Commenting on github issue failed")
+	}
+	return nil
+}
+
+func (t *GitHub) SetLabels(ctx context.Context, repo string, number int, labels []string) error {
+	owner, name := splitRepo(repo)
+	_, resp, err := t.client.Issues.ReplaceLabelsForIssue(ctx, owner, name, number, labels)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		return t.failed("SetLabels()", err, resp, "Updating labels of github issue failed")
+	}
+	return nil
+}
+
+func (t *GitHub) ListLabels(ctx context.Context, repo string) ([]string, error) {
+	owner, name := splitRepo(repo)
+	opts := &github.ListOptions{PerPage: 100}
+	var names []string
+	for {
+		labels, resp, err := t.client.Issues.ListLabels(ctx, owner, name, opts)
+		if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+			return nil, t.failed("ListLabels()", err, resp, "Reading the labels of github repo failed")
+		}
+		for _, label := range labels {
+			names = append(names, label.GetName())
+		}
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+/*
+users that are not collaborators of the repo can not be assigned
+*/
+func (t *GitHub) ValidateAssignees(ctx context.Context, repo string, logins []string) (valid, rejected []string, err error) {
+	owner, name := splitRepo(repo)
+	for _, login := range logins {
+		ok, resp, err := t.client.Issues.IsAssignee(ctx, owner, name, login)
+		if err != nil {
+			return nil, nil, t.failed("ValidateAssignees()", err, resp, "Checking github assignee failed")
+		}
+		if ok {
+			valid = append(valid, login)
+		} else {
+			rejected = append(rejected, login)
+		}
+	}
+	return valid, rejected, nil
+}
+
+func (t *GitHub) AddAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	owner, name := splitRepo(repo)
+	_, resp, err := t.client.Issues.AddAssignees(ctx, owner, name, number, logins)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusCreated) {
+		return t.failed("AddAssignees()", err, resp, "Adding assignees to github issue failed")
+	}
+	return nil
+}
+
+func (t *GitHub) RemoveAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	owner, name := splitRepo(repo)
+	_, resp, err := t.client.Issues.RemoveAssignees(ctx, owner, name, number, logins)
+	if err != nil || (resp != nil && resp.StatusCode != http.StatusOK) {
+		return t.failed("RemoveAssignees()", err, resp, "Removing assignees from github issue failed")
+	}
+	return nil
+}
+
+/*
+log a failed github api call (the response body may contain hints in case of errors) and return the error to report:
+err, wrapped in a StatusError if github answered
+*/
+func (t *GitHub) failed(method string, err error, resp *github.Response, msg string) error {
+	logger := t.logger.WithName(method)
+	if resp == nil {
+		logger.Error(err, msg)
+		return err
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	logger.Error(err, msg, "Github api response code is", resp.StatusCode, "The response body is", string(body))
+	return &StatusError{StatusCode: resp.StatusCode, Err: err}
+}
+
+func fromGithubIssue(issue *github.Issue) *Issue {
+	converted := &Issue{
+		Number:      issue.GetNumber(),
+		NodeID:      issue.GetNodeID(),
+		Title:       issue.GetTitle(),
+		Body:        issue.GetBody(),
+		State:       issue.GetState(),
+		Milestone:   issue.GetMilestone().GetNumber(),
+		Locked:      issue.GetLocked(),
+		HTMLURL:     issue.GetHTMLURL(),
+		UpdatedAt:   issue.GetUpdatedAt(),
+		PullRequest: issue.IsPullRequest(),
+	}
+	for _, label := range issue.Labels {
+		converted.Labels = append(converted.Labels, label.GetName())
+	}
+	for _, assignee := range issue.Assignees {
+		converted.Assignees = append(converted.Assignees, assignee.GetLogin())
+	}
+	return converted
+}
+
+func splitRepo(repo string) (owner, name string) {
+	split := strings.SplitN(repo, "/", 2)
+	if len(split) < 2 {
+		return split[0], ""
+	}
+	return split[0], split[1]
+}
@@ -0,0 +1,211 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v35/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHub", func() {
+	const issuesPath = "/repos/octo/hello/issues"
+	var (
+		ctx = context.Background()
+		api *fakeAPI
+		t   *GitHub
+	)
+
+	BeforeEach(func() {
+		api = newFakeAPI()
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(api.server.URL + "/")
+		t = NewGitHub(client, logr.Discard())
+	})
+
+	AfterEach(func() {
+		api.close()
+	})
+
+	githubIssue := func(number int, title string) map[string]interface{} {
+		return map[string]interface{}{
+			"number":     number,
+			"node_id":    fmt.Sprintf("I_%d", number),
+			"title":      title,
+			"body":       "body of " + title,
+			"state":      "open",
+			"labels":     []map[string]string{{"name": "bug"}},
+			"assignees":  []map[string]string{{"login": "octocat"}},
+			"milestone":  map[string]int{"number": 3},
+			"html_url":   fmt.Sprintf("https://github.com/octo/hello/issues/%d", number),
+			"updated_at": "2021-06-01T10:00:00Z",
+		}
+	}
+
+	Context("Get", func() {
+		It("converts the issue", func() {
+			api.reply("GET", issuesPath+"/1", http.StatusOK, githubIssue(1, "first"))
+
+			issue, err := t.Get(ctx, "octo/hello", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(1))
+			Expect(issue.NodeID).To(Equal("I_1"))
+			Expect(issue.Title).To(Equal("first"))
+			Expect(issue.Body).To(Equal("body of first"))
+			Expect(issue.State).To(Equal(StateOpen))
+			Expect(issue.Labels).To(Equal([]string{"bug"}))
+			Expect(issue.Assignees).To(Equal([]string{"octocat"}))
+			Expect(issue.Milestone).To(Equal(3))
+			Expect(issue.HTMLURL).To(Equal("https://github.com/octo/hello/issues/1"))
+			Expect(issue.UpdatedAt.Year()).To(Equal(2021))
+			Expect(issue.PullRequest).To(BeFalse())
+		})
+
+		It("returns nil for an issue that does not exist (anymore)", func() {
+			api.reply("GET", issuesPath+"/2", http.StatusGone, `{"message": "This issue was deleted"}`)
+
+			issue, err := t.Get(ctx, "octo/hello", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue).To(BeNil())
+			issue, err = t.Get(ctx, "octo/hello", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue).To(BeNil())
+		})
+
+		It("returns other answers as StatusError", func() {
+			api.reply("GET", issuesPath+"/1", http.StatusInternalServerError, `{"message": "oops"}`)
+
+			_, err := t.Get(ctx, "octo/hello", 1)
+			Expect(hasStatus(err, http.StatusInternalServerError)).To(BeTrue())
+		})
+	})
+
+	Context("List", func() {
+		It("follows the pages up to MaxIssues", func() {
+			api.handle("GET", issuesPath, func(w http.ResponseWriter, r *http.Request) {
+				page := r.URL.Query().Get("page")
+				w.Header().Set("ETag", `"etag-`+page+`"`)
+				if page == "1" {
+					w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=2>; rel="next"`, api.server.URL, issuesPath))
+					fmt.Fprintf(w, `[{"number": 1}, {"number": 2}]`)
+					return
+				}
+				w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=3>; rel="next"`, api.server.URL, issuesPath))
+				fmt.Fprintf(w, `[{"number": 3}, {"number": 4}]`)
+			})
+
+			list, err := t.List(ctx, "octo/hello", ListOptions{PageSize: 2, MaxIssues: 3})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Issues).To(HaveLen(3))
+			Expect(list.Issues[2].Number).To(Equal(3))
+			Expect(list.Truncated).To(BeTrue())
+			Expect(list.ETag).To(Equal(`"etag-1"`))
+			Expect(api.requestCount("GET", issuesPath)).To(Equal(2))
+			query := api.lastRequest("GET", issuesPath).Query
+			Expect(query.Get("state")).To(Equal("all"))
+			Expect(query.Get("per_page")).To(Equal("2"))
+		})
+
+		It("answers NotModified if the ETag still matches", func() {
+			api.handle("GET", issuesPath, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-None-Match") == `"etag-1"` {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				fmt.Fprintf(w, `[]`)
+			})
+
+			list, err := t.List(ctx, "octo/hello", ListOptions{ETag: `"etag-1"`})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.NotModified).To(BeTrue())
+			Expect(list.ETag).To(Equal(`"etag-1"`))
+		})
+
+		It("lists the most recently updated issues first if Since is set", func() {
+			api.reply("GET", issuesPath, http.StatusOK, `[]`)
+
+			_, err := t.List(ctx, "octo/hello", ListOptions{State: StateClosed, Since: mustParseTime("2021-06-01T10:00:00Z")})
+			Expect(err).NotTo(HaveOccurred())
+			query := api.lastRequest("GET", issuesPath).Query
+			Expect(query.Get("state")).To(Equal(StateClosed))
+			Expect(query.Get("since")).To(Equal("2021-06-01T10:00:00Z"))
+			Expect(query.Get("sort")).To(Equal("updated"))
+		})
+	})
+
+	Context("editing issues", func() {
+		BeforeEach(func() {
+			api.reply("PATCH", issuesPath+"/1", http.StatusOK, githubIssue(1, "first"))
+		})
+
+		It("removes the milestone with null", func() {
+			none := 0
+			_, err := t.Update(ctx, "octo/hello", 1, IssueUpdate{Milestone: &none})
+			Expect(err).NotTo(HaveOccurred())
+			body := api.lastRequest("PATCH", issuesPath+"/1").json()
+			Expect(body).To(HaveKeyWithValue("milestone", BeNil()))
+			Expect(body).NotTo(HaveKey("title"))
+		})
+
+		It("sends only the fields to change", func() {
+			title := "renamed"
+			_, err := t.Update(ctx, "octo/hello", 1, IssueUpdate{Title: &title})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api.lastRequest("PATCH", issuesPath+"/1").json()).To(Equal(map[string]interface{}{"title": "renamed"}))
+		})
+
+		It("closes with the reason", func() {
+			_, err := t.Close(ctx, "octo/hello", 1, "not_planned")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api.lastRequest("PATCH", issuesPath+"/1").json()).To(Equal(map[string]interface{}{"state": "closed", "state_reason": "not_planned"}))
+		})
+	})
+
+	It("creates issues", func() {
+		api.reply("POST", issuesPath, http.StatusCreated, githubIssue(7, "new"))
+
+		issue, err := t.Create(ctx, "octo/hello", IssueRequest{Title: "new", Body: "text", Labels: []string{"bug"}, Milestone: 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(issue.Number).To(Equal(7))
+		body := api.lastRequest("POST", issuesPath).json()
+		Expect(body).To(HaveKeyWithValue("title", "new"))
+		Expect(body).To(HaveKeyWithValue("labels", ConsistOf("bug")))
+		Expect(body).To(HaveKeyWithValue("milestone", BeEquivalentTo(3)))
+		Expect(body).NotTo(HaveKey("assignees"))
+	})
+
+	It("locks issues as resolved", func() {
+		api.reply("PUT", issuesPath+"/1/lock", http.StatusNoContent, nil)
+
+		Expect(t.Lock(ctx, "octo/hello", 1)).To(Succeed())
+		Expect(api.lastRequest("PUT", issuesPath+"/1/lock").json()).To(HaveKeyWithValue("lock_reason", "resolved"))
+	})
+
+	It("splits the assignees into collaborators and others", func() {
+		api.reply("GET", "/repos/octo/hello/assignees/octocat", http.StatusNoContent, nil)
+
+		valid, rejected, err := t.ValidateAssignees(ctx, "octo/hello", []string{"octocat", "stranger"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(valid).To(Equal([]string{"octocat"}))
+		Expect(rejected).To(Equal([]string{"stranger"}))
+	})
+
+	It("pages through the labels", func() {
+		api.handle("GET", "/repos/octo/hello/labels", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/repos/octo/hello/labels?page=2>; rel="next"`, api.server.URL))
+				fmt.Fprintf(w, `[{"name": "bug"}]`)
+				return
+			}
+			fmt.Fprintf(w, `[{"name": "feature"}]`)
+		})
+
+		labels, err := t.ListLabels(ctx, "octo/hello")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(labels).To(Equal([]string{"bug", "feature"}))
+	})
+})
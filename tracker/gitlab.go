@@ -0,0 +1,344 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// DefaultGitLabURL is the API URL of gitlab.com
+const DefaultGitLabURL = "https://gitlab.com/api/v4/"
+
+// GitLab is the IssueTracker of gitlab.com and self-hosted GitLab instances. repo is the path of the project
+// (group/project, group/subgroup/project), issue numbers are the project-scoped iids. Milestones are not supported
+type GitLab struct {
+	rest   *restClient
+	logger logr.Logger
+}
+
+var _ IssueTracker = &GitLab{}
+
+/*
+baseURL is the API URL, e.g. https://gitlab.example.com/api/v4/. token is a personal, group or project access token
+*/
+func NewGitLab(httpClient *http.Client, baseURL, token string, logger logr.Logger) *GitLab {
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", token)
+	return &GitLab{rest: newRESTClient(httpClient, baseURL, header), logger: logger}
+}
+
+// gitlabIssue is an issue as the GitLab API returns it
+type gitlabIssue struct {
+	ID               int          `json:"id"`
+	IID              int          `json:"iid"`
+	Title            string       `json:"title"`
+	Description      string       `json:"description"`
+	State            string       `json:"state"` // opened or closed
+	Labels           []string     `json:"labels"`
+	Assignees        []gitlabUser `json:"assignees"`
+	DiscussionLocked bool         `json:"discussion_locked"`
+	WebURL           string       `json:"web_url"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
+type gitlabUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// gitlabIssueRequest is the body of an issue creation or edit
+type gitlabIssueRequest struct {
+	Title            *string `json:"title,omitempty"`
+	Description      *string `json:"description,omitempty"`
+	Labels           *string `json:"labels,omitempty"` // comma separated, empty removes all labels
+	AssigneeIDs      *[]int  `json:"assignee_ids,omitempty"`
+	StateEvent       string  `json:"state_event,omitempty"` // close or reopen
+	DiscussionLocked *bool   `json:"discussion_locked,omitempty"`
+}
+
+func (t *GitLab) Get(ctx context.Context, repo string, number int) (*Issue, error) {
+	issue, err := t.getIssue(ctx, repo, number)
+	if hasStatus(err, http.StatusNotFound, http.StatusGone) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, t.failed("Get()", err, "Reading gitlab issue failed")
+	}
+	return issue.toIssue(), nil
+}
+
+func (t *GitLab) getIssue(ctx context.Context, repo string, number int) (*gitlabIssue, error) {
+	issue := &gitlabIssue{}
+	_, err := t.rest.do(ctx, "GET", t.issuePath(repo, number), nil, nil, issue)
+	return issue, err
+}
+
+/*
+pages through the issues of the project (following the X-Next-Page header), newest first, up to opts.MaxIssues issues.
+GitLab has no conditional listing, opts.ETag is ignored
+*/
+func (t *GitLab) List(ctx context.Context, repo string, opts ListOptions) (*IssueList, error) {
+	query := url.Values{}
+	switch opts.State {
+	case StateOpen:
+		query.Set("state", "opened")
+	case StateClosed:
+		query.Set("state", "closed")
+	}
+	if !opts.Since.IsZero() {
+		query.Set("updated_after", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.PageSize > 0 {
+		query.Set("per_page", strconv.Itoa(opts.PageSize))
+	}
+	query.Set("order_by", "created_at")
+	query.Set("sort", "desc")
+	list := &IssueList{}
+	page := "1"
+	for {
+		query.Set("page", page)
+		var issues []gitlabIssue
+		resp, err := t.rest.do(ctx, "GET", t.projectPath(repo)+"/issues", query, nil, &issues)
+		if err != nil {
+			return nil, t.failed("List()", err, "Reading the list of issues from gitlab project failed")
+		}
+		for i := range issues {
+			list.Issues = append(list.Issues, issues[i].toIssue())
+		}
+		page = resp.Header.Get("X-Next-Page")
+		if opts.MaxIssues > 0 && len(list.Issues) >= opts.MaxIssues {
+			list.Truncated = page != "" || len(list.Issues) > opts.MaxIssues
+			list.Issues = list.Issues[:opts.MaxIssues]
+			return list, nil
+		}
+		if page == "" {
+			return list, nil
+		}
+	}
+}
+
+func (t *GitLab) Create(ctx context.Context, repo string, issue IssueRequest) (*Issue, error) {
+	if issue.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	labels := strings.Join(issue.Labels, ",")
+	body := &gitlabIssueRequest{Title: &issue.Title, Description: &issue.Body, Labels: &labels}
+	if len(issue.Assignees) > 0 {
+		ids, err := t.userIDs(ctx, issue.Assignees)
+		if err != nil {
+			return nil, t.failed("Create()", err, "Looking up gitlab assignees failed")
+		}
+		body.AssigneeIDs = &ids
+	}
+	created := &gitlabIssue{}
+	_, err := t.rest.do(ctx, "POST", t.projectPath(repo)+"/issues", nil, body, created)
+	if err != nil {
+		return nil, t.failed("Create()", err, "Creation of gitlab issue failed")
+	}
+	return created.toIssue(), nil
+}
+
+func (t *GitLab) Update(ctx context.Context, repo string, number int, update IssueUpdate) (*Issue, error) {
+	if update.Milestone != nil && *update.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	if update.Title == nil && update.Body == nil {
+		return t.Get(ctx, repo, number)
+	}
+	return t.edit(ctx, repo, number, &gitlabIssueRequest{Title: update.Title, Description: update.Body}, "Update()")
+}
+
+/*
+GitLab has no close reason, reason is ignored
+*/
+func (t *GitLab) Close(ctx context.Context, repo string, number int, reason string) (*Issue, error) {
+	return t.edit(ctx, repo, number, &gitlabIssueRequest{StateEvent: "close"}, "Close()")
+}
+
+func (t *GitLab) Reopen(ctx context.Context, repo string, number int) (*Issue, error) {
+	return t.edit(ctx, repo, number, &gitlabIssueRequest{StateEvent: "reopen"}, "Reopen()")
+}
+
+func (t *GitLab) Lock(ctx context.Context, repo string, number int) error {
+	locked := true
+	_, err := t.edit(ctx, repo, number, &gitlabIssueRequest{DiscussionLocked: &locked}, "Lock()")
+	return err
+}
+
+func (t *GitLab) edit(ctx context.Context, repo string, number int, body *gitlabIssueRequest, method string) (*Issue, error) {
+	issue := &gitlabIssue{}
+	_, err := t.rest.do(ctx, "PUT", t.issuePath(repo, number), nil, body, issue)
+	if err != nil {
+		return nil, t.failed(method, err, "Updating gitlab issue failed")
+	}
+	return issue.toIssue(), nil
+}
+
+func (t *GitLab) Comment(ctx context.Context, repo string, number int, body string) error {
+	_, err := t.rest.do(ctx, "POST", t.issuePath(repo, number)+"/notes", nil, map[string]string{"body": body}, nil)
+	if err != nil {
+		return t.failed("Comment()", err, "Commenting on gitlab issue failed")
+	}
+	return nil
+}
+
+func (t *GitLab) SetLabels(ctx context.Context, repo string, number int, labels []string) error {
+	joined := strings.Join(labels, ",")
+	_, err := t.edit(ctx, repo, number, &gitlabIssueRequest{Labels: &joined}, "SetLabels()")
+	return err
+}
+
+func (t *GitLab) ListLabels(ctx context.Context, repo string) ([]string, error) {
+	query := url.Values{}
+	query.Set("per_page", "100")
+	var names []string
+	page := "1"
+	for page != "" {
+		query.Set("page", page)
+		var labels []struct {
+			Name string `json:"name"`
+		}
+		resp, err := t.rest.do(ctx, "GET", t.projectPath(repo)+"/labels", query, nil, &labels)
+		if err != nil {
+			return nil, t.failed("ListLabels()", err, "Reading the labels of gitlab project failed")
+		}
+		for _, label := range labels {
+			names = append(names, label.Name)
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return names, nil
+}
+
+/*
+users that do not exist or are no members of the project (directly or through its groups) can not be assigned
+*/
+func (t *GitLab) ValidateAssignees(ctx context.Context, repo string, logins []string) (valid, rejected []string, err error) {
+	for _, login := range logins {
+		id, err := t.userID(ctx, login)
+		if err != nil {
+			return nil, nil, t.failed("ValidateAssignees()", err, "Looking up gitlab user failed")
+		}
+		if id != 0 {
+			_, err = t.rest.do(ctx, "GET", fmt.Sprintf("%s/members/all/%d", t.projectPath(repo), id), nil, nil, nil)
+			if hasStatus(err, http.StatusNotFound) {
+				id = 0
+			} else if err != nil {
+				return nil, nil, t.failed("ValidateAssignees()", err, "Checking gitlab project member failed")
+			}
+		}
+		if id != 0 {
+			valid = append(valid, login)
+		} else {
+			rejected = append(rejected, login)
+		}
+	}
+	return valid, rejected, nil
+}
+
+/*
+GitLab only sets the assignees as a whole
+*/
+func (t *GitLab) AddAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	return t.changeAssignees(ctx, repo, number, logins, nil, "AddAssignees()")
+}
+
+func (t *GitLab) RemoveAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	return t.changeAssignees(ctx, repo, number, nil, logins, "RemoveAssignees()")
+}
+
+func (t *GitLab) changeAssignees(ctx context.Context, repo string, number int, add, remove []string, method string) error {
+	issue, err := t.getIssue(ctx, repo, number)
+	if err != nil {
+		return t.failed(method, err, "Reading gitlab issue failed")
+	}
+	removed := map[string]bool{}
+	for _, login := range remove {
+		removed[strings.ToLower(login)] = true
+	}
+	ids := []int{}
+	for _, user := range issue.Assignees {
+		if !removed[strings.ToLower(user.Username)] {
+			ids = append(ids, user.ID)
+		}
+	}
+	added, err := t.userIDs(ctx, add)
+	if err != nil {
+		return t.failed(method, err, "Looking up gitlab assignees failed")
+	}
+	ids = append(ids, added...)
+	_, err = t.edit(ctx, repo, number, &gitlabIssueRequest{AssigneeIDs: &ids}, method)
+	return err
+}
+
+/*
+the ids of the users, skipping users that do not exist
+*/
+func (t *GitLab) userIDs(ctx context.Context, logins []string) ([]int, error) {
+	ids := []int{}
+	for _, login := range logins {
+		id, err := t.userID(ctx, login)
+		if err != nil {
+			return nil, err
+		}
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+/*
+0 if there is no such user
+*/
+func (t *GitLab) userID(ctx context.Context, login string) (int, error) {
+	var users []gitlabUser
+	_, err := t.rest.do(ctx, "GET", "users", url.Values{"username": {login}}, nil, &users)
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+	return users[0].ID, nil
+}
+
+/*
+projects are addressed by their URL encoded path
+*/
+func (t *GitLab) projectPath(repo string) string {
+	return "projects/" + url.PathEscape(repo)
+}
+
+func (t *GitLab) issuePath(repo string, number int) string {
+	return fmt.Sprintf("%s/issues/%d", t.projectPath(repo), number)
+}
+
+func (t *GitLab) failed(method string, err error, msg string) error {
+	t.logger.WithName(method).Error(err, msg)
+	return err
+}
+
+func (issue *gitlabIssue) toIssue() *Issue {
+	converted := &Issue{
+		Number:    issue.IID,
+		NodeID:    fmt.Sprintf("gid://gitlab/Issue/%d", issue.ID),
+		Title:     issue.Title,
+		Body:      issue.Description,
+		State:     StateOpen,
+		Labels:    issue.Labels,
+		Locked:    issue.DiscussionLocked,
+		HTMLURL:   issue.WebURL,
+		UpdatedAt: issue.UpdatedAt,
+	}
+	if issue.State == "closed" {
+		converted.State = StateClosed
+	}
+	for _, user := range issue.Assignees {
+		converted.Assignees = append(converted.Assignees, user.Username)
+	}
+	return converted
+}
@@ -0,0 +1,195 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitLab", func() {
+	const projectPath = "/api/v4/projects/group%2Fsub%2Fhello"
+	var (
+		ctx = context.Background()
+		api *fakeAPI
+		t   *GitLab
+	)
+
+	BeforeEach(func() {
+		api = newFakeAPI()
+		t = NewGitLab(nil, api.server.URL+"/api/v4", "gitlab-token", logr.Discard())
+		/* octocat and hubot are members of the project, outsider is not, other users do not exist */
+		users := []gitlabUser{{ID: 10, Username: "octocat"}, {ID: 11, Username: "hubot"}, {ID: 12, Username: "outsider"}}
+		api.handle("GET", "/api/v4/users", func(w http.ResponseWriter, r *http.Request) {
+			for _, user := range users {
+				if user.Username == r.URL.Query().Get("username") {
+					fmt.Fprintf(w, `[{"id": %d, "username": %q}]`, user.ID, user.Username)
+					return
+				}
+			}
+			fmt.Fprintf(w, `[]`)
+		})
+		api.reply("GET", projectPath+"/members/all/10", http.StatusOK, `{}`)
+		api.reply("GET", projectPath+"/members/all/11", http.StatusOK, `{}`)
+	})
+
+	AfterEach(func() {
+		api.close()
+	})
+
+	gitlabIssueJSON := func(iid int, state string, assignees ...string) string {
+		users := ""
+		for i, login := range assignees {
+			if i > 0 {
+				users += ","
+			}
+			users += fmt.Sprintf(`{"id": %d, "username": %q}`, 10+i, login)
+		}
+		return fmt.Sprintf(`{"id": %d, "iid": %d, "title": "issue %d", "description": "text", "state": %q, "labels": ["bug"],
+			"assignees": [%s], "web_url": "https://gitlab.example.com/group/sub/hello/-/issues/%d", "updated_at": "2021-06-01T10:00:00Z"}`,
+			1000+iid, iid, iid, state, users, iid)
+	}
+
+	Context("Get", func() {
+		It("converts the issue of a project in a subgroup", func() {
+			api.reply("GET", projectPath+"/issues/1", http.StatusOK, gitlabIssueJSON(1, "opened", "octocat"))
+
+			issue, err := t.Get(ctx, "group/sub/hello", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(1))
+			Expect(issue.NodeID).To(Equal("gid://gitlab/Issue/1001"))
+			Expect(issue.Title).To(Equal("issue 1"))
+			Expect(issue.Body).To(Equal("text"))
+			Expect(issue.State).To(Equal(StateOpen))
+			Expect(issue.Labels).To(Equal([]string{"bug"}))
+			Expect(issue.Assignees).To(Equal([]string{"octocat"}))
+			Expect(issue.HTMLURL).To(Equal("https://gitlab.example.com/group/sub/hello/-/issues/1"))
+			Expect(api.lastRequest("GET", projectPath+"/issues/1").Header.Get("PRIVATE-TOKEN")).To(Equal("gitlab-token"))
+		})
+
+		It("maps the closed state", func() {
+			api.reply("GET", projectPath+"/issues/2", http.StatusOK, gitlabIssueJSON(2, "closed"))
+
+			issue, err := t.Get(ctx, "group/sub/hello", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.State).To(Equal(StateClosed))
+		})
+
+		It("returns nil for an issue that does not exist", func() {
+			issue, err := t.Get(ctx, "group/sub/hello", 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue).To(BeNil())
+		})
+	})
+
+	It("follows X-Next-Page up to MaxIssues", func() {
+		api.handle("GET", projectPath+"/issues", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprintf(w, "[%s, %s]", gitlabIssueJSON(4, "opened"), gitlabIssueJSON(3, "opened"))
+				return
+			}
+			w.Header().Set("X-Next-Page", "3")
+			fmt.Fprintf(w, "[%s, %s]", gitlabIssueJSON(2, "opened"), gitlabIssueJSON(1, "opened"))
+		})
+
+		list, err := t.List(ctx, "group/sub/hello", ListOptions{State: StateOpen, PageSize: 2, MaxIssues: 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(list.Issues).To(HaveLen(3))
+		Expect(list.Issues[2].Number).To(Equal(2))
+		Expect(list.Truncated).To(BeTrue())
+		Expect(list.ETag).To(BeEmpty())
+		query := api.lastRequest("GET", projectPath+"/issues").Query
+		Expect(query.Get("state")).To(Equal("opened"))
+		Expect(query.Get("per_page")).To(Equal("2"))
+	})
+
+	Context("Create", func() {
+		It("assigns the users that exist", func() {
+			api.reply("POST", projectPath+"/issues", http.StatusCreated, gitlabIssueJSON(5, "opened", "octocat"))
+
+			issue, err := t.Create(ctx, "group/sub/hello", IssueRequest{Title: "new", Body: "text", Labels: []string{"bug", "ui"}, Assignees: []string{"octocat", "ghost"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(5))
+			body := api.lastRequest("POST", projectPath+"/issues").json()
+			Expect(body).To(HaveKeyWithValue("title", "new"))
+			Expect(body).To(HaveKeyWithValue("description", "text"))
+			Expect(body).To(HaveKeyWithValue("labels", "bug,ui"))
+			Expect(body).To(HaveKeyWithValue("assignee_ids", ConsistOf(BeEquivalentTo(10))))
+		})
+
+		It("does not support milestones", func() {
+			_, err := t.Create(ctx, "group/sub/hello", IssueRequest{Title: "new", Milestone: 1})
+			Expect(errors.Is(err, ErrNotSupported)).To(BeTrue())
+			Expect(api.requestCount("POST", projectPath+"/issues")).To(BeZero())
+		})
+	})
+
+	Context("editing issues", func() {
+		BeforeEach(func() {
+			api.reply("GET", projectPath+"/issues/1", http.StatusOK, gitlabIssueJSON(1, "opened", "octocat", "hubot"))
+			api.reply("PUT", projectPath+"/issues/1", http.StatusOK, gitlabIssueJSON(1, "opened"))
+		})
+
+		It("closes with a state event", func() {
+			_, err := t.Close(ctx, "group/sub/hello", 1, "completed")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api.lastRequest("PUT", projectPath+"/issues/1").json()).To(Equal(map[string]interface{}{"state_event": "close"}))
+		})
+
+		It("locks the discussion", func() {
+			Expect(t.Lock(ctx, "group/sub/hello", 1)).To(Succeed())
+			Expect(api.lastRequest("PUT", projectPath+"/issues/1").json()).To(Equal(map[string]interface{}{"discussion_locked": true}))
+		})
+
+		It("removes all labels with an empty list", func() {
+			Expect(t.SetLabels(ctx, "group/sub/hello", 1, nil)).To(Succeed())
+			Expect(api.lastRequest("PUT", projectPath+"/issues/1").json()).To(Equal(map[string]interface{}{"labels": ""}))
+		})
+
+		It("does not write an update without title and description", func() {
+			none := 0
+			issue, err := t.Update(ctx, "group/sub/hello", 1, IssueUpdate{Milestone: &none})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(1))
+			Expect(api.requestCount("PUT", projectPath+"/issues/1")).To(BeZero())
+		})
+
+		It("keeps the other assignees when removing one", func() {
+			Expect(t.RemoveAssignees(ctx, "group/sub/hello", 1, []string{"HUBOT"})).To(Succeed())
+			Expect(api.lastRequest("PUT", projectPath+"/issues/1").json()).To(HaveKeyWithValue("assignee_ids", ConsistOf(BeEquivalentTo(10))))
+		})
+
+		It("keeps the current assignees when adding one", func() {
+			Expect(t.AddAssignees(ctx, "group/sub/hello", 1, []string{"outsider"})).To(Succeed())
+			Expect(api.lastRequest("PUT", projectPath+"/issues/1").json()).To(HaveKeyWithValue("assignee_ids",
+				ConsistOf(BeEquivalentTo(10), BeEquivalentTo(11), BeEquivalentTo(12))))
+		})
+
+		It("posts comments as notes", func() {
+			api.reply("POST", projectPath+"/issues/1/notes", http.StatusCreated, `{}`)
+
+			Expect(t.Comment(ctx, "group/sub/hello", 1, "bye")).To(Succeed())
+			Expect(api.lastRequest("POST", projectPath+"/issues/1/notes").json()).To(Equal(map[string]interface{}{"body": "bye"}))
+		})
+	})
+
+	It("rejects assignees that do not exist or are no members of the project", func() {
+		valid, rejected, err := t.ValidateAssignees(ctx, "group/sub/hello", []string{"octocat", "ghost", "outsider"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(valid).To(Equal([]string{"octocat"}))
+		Expect(rejected).To(Equal([]string{"ghost", "outsider"}))
+	})
+
+	It("returns failed calls as StatusError", func() {
+		api.reply("PUT", projectPath+"/issues/1", http.StatusForbidden, `{"message": "403 Forbidden"}`)
+
+		_, err := t.Reopen(ctx, "group/sub/hello", 1)
+		Expect(hasStatus(err, http.StatusForbidden)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("403 Forbidden"))
+	})
+})
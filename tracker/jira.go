@@ -0,0 +1,581 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// jiraBodyProperty is the issue property holding the Markdown the description was rendered from
+	jiraBodyProperty = "githubissue-operator"
+	// jiraFields are the fields read from Jira issues
+	jiraFields = "summary,description,status,labels,assignee,updated"
+	// jiraTimeLayout is how Jira formats timestamps
+	jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+	// jiraDoneCategory is the key of the status category of closed issues
+	jiraDoneCategory = "done"
+)
+
+// JiraOptions maps issues onto a Jira installation. Empty fields take the defaults of NewJira
+type JiraOptions struct {
+	// Server selects Jira Server / Data Center (REST API v2, wiki markup) instead of Jira Cloud (REST API v3,
+	// Atlassian Document Format)
+	Server bool
+	// IssueType of created issues
+	IssueType string
+	// CloseTransition and ReopenTransition name the workflow transitions (or the statuses they lead to) that close
+	// and reopen issues
+	CloseTransition  string
+	ReopenTransition string
+}
+
+// Jira is the IssueTracker of Jira Cloud and Jira Server / Data Center. repo is the project key and an issue's number
+// is the number in its key (123 for PROJECT-123). Descriptions are converted from Markdown, the Markdown itself is
+// kept in an issue property so the description reads back unchanged as long as nobody edits it in Jira. Jira issues
+// have a single assignee, milestones and locking are not supported
+type Jira struct {
+	rest    *restClient
+	siteURL string
+	opts    JiraOptions
+	logger  logr.Logger
+}
+
+var _ IssueTracker = &Jira{}
+
+/*
+siteURL is the address of the Jira site, e.g. https://example.atlassian.net. With a username (the account's email on
+Jira Cloud) token is an API token sent with basic authentication, otherwise a personal access token (Jira Server)
+*/
+func NewJira(httpClient *http.Client, siteURL, username, token string, opts JiraOptions, logger logr.Logger) *Jira {
+	if !strings.HasSuffix(siteURL, "/") {
+		siteURL += "/"
+	}
+	if opts.IssueType == "" {
+		opts.IssueType = "Task"
+	}
+	if opts.CloseTransition == "" {
+		opts.CloseTransition = "Done"
+	}
+	if opts.ReopenTransition == "" {
+		opts.ReopenTransition = "To Do"
+	}
+	header := http.Header{}
+	if username != "" {
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+token)))
+	} else {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	apiURL := siteURL + "rest/api/3/"
+	if opts.Server {
+		apiURL = siteURL + "rest/api/2/"
+	}
+	return &Jira{rest: newRESTClient(httpClient, apiURL, header), siteURL: siteURL, opts: opts, logger: logger}
+}
+
+// jiraIssue is an issue as the Jira API returns it
+type jiraIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Fields struct {
+		Summary string `json:"summary"`
+		// Description is a string on API v2 and a document on API v3
+		Description json.RawMessage `json:"description"`
+		Status      struct {
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"status"`
+		Labels   []string  `json:"labels"`
+		Assignee *jiraUser `json:"assignee"`
+		Updated  string    `json:"updated"`
+	} `json:"fields"`
+	Properties map[string]jiraBody `json:"properties"`
+}
+
+type jiraUser struct {
+	AccountID string `json:"accountId,omitempty"` // Jira Cloud
+	Name      string `json:"name,omitempty"`      // Jira Server
+}
+
+// jiraBody is the value of the jiraBodyProperty
+type jiraBody struct {
+	// Body is the Markdown the description was rendered from
+	Body string `json:"body"`
+	// DescriptionHash is the hash of the rendered description, the Markdown is stale if they differ
+	DescriptionHash string `json:"descriptionHash"`
+}
+
+func (t *Jira) Get(ctx context.Context, repo string, number int) (*Issue, error) {
+	issue, err := t.getIssue(ctx, jiraKey(repo, number), jiraFields)
+	if hasStatus(err, http.StatusNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, t.failed("Get()", err, "Reading jira issue failed")
+	}
+	/* Jira redirects to issues moved to another project, they are not the issue asked for anymore */
+	if !strings.HasPrefix(issue.Key, repo+"-") {
+		return nil, nil
+	}
+	return t.toIssue(issue), nil
+}
+
+func (t *Jira) getIssue(ctx context.Context, key, fields string) (*jiraIssue, error) {
+	query := url.Values{}
+	query.Set("fields", fields)
+	query.Set("properties", jiraBodyProperty)
+	issue := &jiraIssue{}
+	_, err := t.rest.do(ctx, "GET", "issue/"+url.PathEscape(key), query, nil, issue)
+	return issue, err
+}
+
+/*
+pages through the issues of the project, newest first, up to opts.MaxIssues issues. Jira Cloud pages with a token,
+Jira Server with an offset. Jira has no conditional listing, opts.ETag is ignored
+*/
+func (t *Jira) List(ctx context.Context, repo string, opts ListOptions) (*IssueList, error) {
+	jql := fmt.Sprintf("project = %s", strconv.Quote(repo))
+	switch opts.State {
+	case StateOpen:
+		jql += " AND statusCategory != Done"
+	case StateClosed:
+		jql += " AND statusCategory = Done"
+	}
+	if !opts.Since.IsZero() {
+		/* relative to now, as absolute JQL dates are in the user's time zone and have minute precision */
+		minutes := int(time.Since(opts.Since).Minutes()) + 1
+		jql += fmt.Sprintf(` AND updated >= "-%dm"`, minutes)
+	}
+	jql += " ORDER BY created DESC"
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	request := map[string]interface{}{
+		"jql":        jql,
+		"maxResults": pageSize,
+		"fields":     strings.Split(jiraFields, ","),
+		"properties": []string{jiraBodyProperty},
+	}
+	path := "search/jql"
+	if t.opts.Server {
+		path = "search"
+	}
+	list := &IssueList{}
+	for {
+		var page struct {
+			Issues        []jiraIssue `json:"issues"`
+			Total         int         `json:"total"`         // Jira Server
+			NextPageToken string      `json:"nextPageToken"` // Jira Cloud
+			IsLast        bool        `json:"isLast"`        // Jira Cloud
+		}
+		if t.opts.Server {
+			request["startAt"] = len(list.Issues)
+		}
+		_, err := t.rest.do(ctx, "POST", path, nil, request, &page)
+		if err != nil {
+			return nil, t.failed("List()", err, "Reading the list of issues from jira project failed")
+		}
+		for i := range page.Issues {
+			list.Issues = append(list.Issues, t.toIssue(&page.Issues[i]))
+		}
+		next := len(page.Issues) > 0 && !page.IsLast && page.NextPageToken != ""
+		if t.opts.Server {
+			next = len(page.Issues) > 0 && len(list.Issues) < page.Total
+		}
+		if opts.MaxIssues > 0 && len(list.Issues) >= opts.MaxIssues {
+			list.Truncated = next || len(list.Issues) > opts.MaxIssues
+			list.Issues = list.Issues[:opts.MaxIssues]
+			return list, nil
+		}
+		if !next {
+			return list, nil
+		}
+		request["nextPageToken"] = page.NextPageToken
+	}
+}
+
+/*
+the issue is created with JiraOptions.IssueType, and with the first of issue.Assignees as assignee
+*/
+func (t *Jira) Create(ctx context.Context, repo string, issue IssueRequest) (*Issue, error) {
+	if issue.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": repo},
+		"issuetype":   map[string]string{"name": t.opts.IssueType},
+		"summary":     issue.Title,
+		"description": t.description(issue.Body),
+		"labels":      nonNil(issue.Labels),
+	}
+	if len(issue.Assignees) > 0 {
+		fields["assignee"] = t.user(issue.Assignees[0])
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	_, err := t.rest.do(ctx, "POST", "issue", nil, map[string]interface{}{"fields": fields}, &created)
+	if err != nil {
+		return nil, t.failed("Create()", err, "Creation of jira issue failed")
+	}
+	err = t.recordBody(ctx, created.Key, issue.Body)
+	if err != nil {
+		return nil, t.failed("Create()", err, "Recording the description of jira issue failed")
+	}
+	return t.get(ctx, created.Key, "Create()")
+}
+
+func (t *Jira) Update(ctx context.Context, repo string, number int, update IssueUpdate) (*Issue, error) {
+	if update.Milestone != nil && *update.Milestone != 0 {
+		return nil, fmt.Errorf("milestones: %w", ErrNotSupported)
+	}
+	key := jiraKey(repo, number)
+	fields := map[string]interface{}{}
+	if update.Title != nil {
+		fields["summary"] = *update.Title
+	}
+	if update.Body != nil {
+		fields["description"] = t.description(*update.Body)
+	}
+	if len(fields) > 0 {
+		err := t.editFields(ctx, key, fields)
+		if err != nil {
+			return nil, t.failed("Update()", err, "Updating jira issue failed")
+		}
+	}
+	if update.Body != nil {
+		err := t.recordBody(ctx, key, *update.Body)
+		if err != nil {
+			return nil, t.failed("Update()", err, "Recording the description of jira issue failed")
+		}
+	}
+	return t.get(ctx, key, "Update()")
+}
+
+/*
+applies JiraOptions.CloseTransition. Jira has no close reason in general (it is configured per workflow), reason is
+ignored
+*/
+func (t *Jira) Close(ctx context.Context, repo string, number int, reason string) (*Issue, error) {
+	return t.transition(ctx, jiraKey(repo, number), t.opts.CloseTransition, "Close()")
+}
+
+/*
+applies JiraOptions.ReopenTransition
+*/
+func (t *Jira) Reopen(ctx context.Context, repo string, number int) (*Issue, error) {
+	return t.transition(ctx, jiraKey(repo, number), t.opts.ReopenTransition, "Reopen()")
+}
+
+/*
+the transitions available depend on the issue's workflow and current status, name matches the name of a transition
+or of the status it leads to (case insensitive)
+*/
+func (t *Jira) transition(ctx context.Context, key, name, method string) (*Issue, error) {
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	_, err := t.rest.do(ctx, "GET", "issue/"+url.PathEscape(key)+"/transitions", nil, nil, &available)
+	if err != nil {
+		return nil, t.failed(method, err, "Reading the transitions of jira issue failed")
+	}
+	var names []string
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, name) || strings.EqualFold(transition.To.Name, name) {
+			body := map[string]interface{}{"transition": map[string]string{"id": transition.ID}}
+			_, err = t.rest.do(ctx, "POST", "issue/"+url.PathEscape(key)+"/transitions", nil, body, nil)
+			if err != nil {
+				return nil, t.failed(method, err, "Transitioning jira issue failed")
+			}
+			return t.get(ctx, key, method)
+		}
+		names = append(names, transition.Name)
+	}
+	return nil, t.failed(method, fmt.Errorf("jira issue %s has no transition %q, available: %s", key, name, strings.Join(names, ", ")), "Transitioning jira issue failed")
+}
+
+/*
+Jira has no locking in general (it is configured per workflow)
+*/
+func (t *Jira) Lock(ctx context.Context, repo string, number int) error {
+	return fmt.Errorf("locking issues: %w", ErrNotSupported)
+}
+
+/*
+body is Markdown, converted like descriptions
+*/
+func (t *Jira) Comment(ctx context.Context, repo string, number int, body string) error {
+	key := jiraKey(repo, number)
+	_, err := t.rest.do(ctx, "POST", "issue/"+url.PathEscape(key)+"/comment", nil, map[string]interface{}{"body": t.description(body)}, nil)
+	if err != nil {
+		return t.failed("Comment()", err, "Commenting on jira issue failed")
+	}
+	return nil
+}
+
+/*
+Jira labels are free text (without spaces) and need not be created first
+*/
+func (t *Jira) SetLabels(ctx context.Context, repo string, number int, labels []string) error {
+	err := t.editFields(ctx, jiraKey(repo, number), map[string]interface{}{"labels": nonNil(labels)})
+	if err != nil {
+		return t.failed("SetLabels()", err, "Updating the labels of jira issue failed")
+	}
+	return nil
+}
+
+/*
+Jira labels are not per project, the labels of the entire site are returned. Jira Server can not list labels
+*/
+func (t *Jira) ListLabels(ctx context.Context, repo string) ([]string, error) {
+	if t.opts.Server {
+		return nil, fmt.Errorf("listing labels: %w", ErrNotSupported)
+	}
+	var labels []string
+	query := url.Values{}
+	for {
+		query.Set("startAt", strconv.Itoa(len(labels)))
+		var page struct {
+			Values []string `json:"values"`
+			IsLast bool     `json:"isLast"`
+		}
+		_, err := t.rest.do(ctx, "GET", "label", query, nil, &page)
+		if err != nil {
+			return nil, t.failed("ListLabels()", err, "Reading the labels of jira site failed")
+		}
+		labels = append(labels, page.Values...)
+		if page.IsLast || len(page.Values) == 0 {
+			return labels, nil
+		}
+	}
+}
+
+/*
+only users assignable in the project are valid. A Jira issue has a single assignee, only the first of logins can be
+valid. logins are account IDs on Jira Cloud and usernames on Jira Server
+*/
+func (t *Jira) ValidateAssignees(ctx context.Context, repo string, logins []string) (valid, rejected []string, err error) {
+	for i, login := range logins {
+		if i > 0 {
+			rejected = append(rejected, login)
+			continue
+		}
+		query := url.Values{}
+		query.Set("project", repo)
+		if t.opts.Server {
+			query.Set("username", login)
+		} else {
+			query.Set("accountId", login)
+		}
+		var users []jiraUser
+		_, err = t.rest.do(ctx, "GET", "user/assignable/search", query, nil, &users)
+		if err != nil {
+			return nil, nil, t.failed("ValidateAssignees()", err, "Reading the assignable users of jira project failed")
+		}
+		assignable := false
+		for _, user := range users {
+			assignable = assignable || strings.EqualFold(user.login(), login)
+		}
+		if assignable {
+			valid = append(valid, login)
+		} else {
+			rejected = append(rejected, login)
+		}
+	}
+	return valid, rejected, nil
+}
+
+/*
+replaces the assignee with the first of logins
+*/
+func (t *Jira) AddAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	if len(logins) == 0 {
+		return nil
+	}
+	return t.assign(ctx, jiraKey(repo, number), t.user(logins[0]), "AddAssignees()")
+}
+
+/*
+unassigns the issue if its assignee is one of logins
+*/
+func (t *Jira) RemoveAssignees(ctx context.Context, repo string, number int, logins []string) error {
+	key := jiraKey(repo, number)
+	issue, err := t.getIssue(ctx, key, "assignee")
+	if err != nil {
+		return t.failed("RemoveAssignees()", err, "Reading jira issue failed")
+	}
+	if issue.Fields.Assignee == nil {
+		return nil
+	}
+	for _, login := range logins {
+		if strings.EqualFold(issue.Fields.Assignee.login(), login) {
+			return t.assign(ctx, key, nil, "RemoveAssignees()")
+		}
+	}
+	return nil
+}
+
+func (t *Jira) assign(ctx context.Context, key string, user *jiraUser, method string) error {
+	var body interface{} = user
+	if user == nil {
+		/* unassigning takes an explicit null */
+		body = map[string]interface{}{t.userField(): nil}
+	}
+	_, err := t.rest.do(ctx, "PUT", "issue/"+url.PathEscape(key)+"/assignee", nil, body, nil)
+	if err != nil {
+		return t.failed(method, err, "Updating the assignee of jira issue failed")
+	}
+	return nil
+}
+
+func (t *Jira) editFields(ctx context.Context, key string, fields map[string]interface{}) error {
+	_, err := t.rest.do(ctx, "PUT", "issue/"+url.PathEscape(key), nil, map[string]interface{}{"fields": fields}, nil)
+	return err
+}
+
+/*
+stores body next to the description it was rendered to, as Jira stores it, so Get can tell whether the description
+was edited since
+*/
+func (t *Jira) recordBody(ctx context.Context, key, body string) error {
+	issue, err := t.getIssue(ctx, key, "description")
+	if err != nil {
+		return err
+	}
+	property := jiraBody{Body: body, DescriptionHash: descriptionHash(issue.Fields.Description)}
+	_, err = t.rest.do(ctx, "PUT", "issue/"+url.PathEscape(key)+"/properties/"+jiraBodyProperty, nil, property, nil)
+	return err
+}
+
+func (t *Jira) get(ctx context.Context, key, method string) (*Issue, error) {
+	issue, err := t.getIssue(ctx, key, jiraFields)
+	if err != nil {
+		return nil, t.failed(method, err, "Reading jira issue failed")
+	}
+	return t.toIssue(issue), nil
+}
+
+/*
+the description field for Markdown: a document for Jira Cloud, wiki markup for Jira Server. Jira Cloud rejects a
+document without content, an empty description is null
+*/
+func (t *Jira) description(markdown string) interface{} {
+	if t.opts.Server {
+		return markdownToWiki(markdown)
+	}
+	doc := markdownToADF(markdown)
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	return doc
+}
+
+func (t *Jira) user(login string) *jiraUser {
+	if t.opts.Server {
+		return &jiraUser{Name: login}
+	}
+	return &jiraUser{AccountID: login}
+}
+
+func (t *Jira) userField() string {
+	if t.opts.Server {
+		return "name"
+	}
+	return "accountId"
+}
+
+func (t *Jira) failed(method string, err error, msg string) error {
+	t.logger.WithName(method).Error(err, msg)
+	return err
+}
+
+/*
+the body is the recorded Markdown if the description is still the one rendered from it, otherwise the text of the
+description
+*/
+func (t *Jira) toIssue(issue *jiraIssue) *Issue {
+	converted := &Issue{
+		Number:  jiraNumber(issue.Key),
+		NodeID:  issue.ID,
+		Key:     issue.Key,
+		Title:   issue.Fields.Summary,
+		State:   StateOpen,
+		Labels:  issue.Fields.Labels,
+		HTMLURL: t.siteURL + "browse/" + issue.Key,
+	}
+	if issue.Fields.Status.StatusCategory.Key == jiraDoneCategory {
+		converted.State = StateClosed
+	}
+	if issue.Fields.Assignee != nil {
+		converted.Assignees = []string{issue.Fields.Assignee.login()}
+	}
+	converted.UpdatedAt, _ = time.Parse(jiraTimeLayout, issue.Fields.Updated)
+	recorded, ok := issue.Properties[jiraBodyProperty]
+	if ok && recorded.DescriptionHash == descriptionHash(issue.Fields.Description) {
+		converted.Body = recorded.Body
+		return converted
+	}
+	if t.opts.Server {
+		_ = json.Unmarshal(issue.Fields.Description, &converted.Body)
+	} else {
+		doc := &adfNode{}
+		if json.Unmarshal(issue.Fields.Description, doc) == nil {
+			converted.Body = adfText(doc)
+		}
+	}
+	return converted
+}
+
+func (user *jiraUser) login() string {
+	if user.AccountID != "" {
+		return user.AccountID
+	}
+	return user.Name
+}
+
+func descriptionHash(description json.RawMessage) string {
+	compact := &bytes.Buffer{}
+	if json.Compact(compact, description) != nil {
+		compact.Write(description)
+	}
+	sum := sha256.Sum256(compact.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func jiraKey(repo string, number int) string {
+	return repo + "-" + strconv.Itoa(number)
+}
+
+/*
+the number in an issue key, 123 for PROJECT-123
+*/
+func jiraNumber(key string) int {
+	number, _ := strconv.Atoi(key[strings.LastIndex(key, "-")+1:])
+	return number
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,276 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Jira", func() {
+	var (
+		ctx = context.Background()
+		api *fakeAPI
+	)
+
+	BeforeEach(func() {
+		api = newFakeAPI()
+	})
+
+	AfterEach(func() {
+		api.close()
+	})
+
+	/* a Jira Cloud issue, with the Markdown recorded for description (if not nil) */
+	jiraIssueJSON := func(key, category string, description interface{}, recorded *jiraBody) string {
+		issue := map[string]interface{}{
+			"id":  "10001",
+			"key": key,
+			"fields": map[string]interface{}{
+				"summary":     "summary of " + key,
+				"description": description,
+				"status":      map[string]interface{}{"statusCategory": map[string]string{"key": category}},
+				"labels":      []string{"bug"},
+				"assignee":    map[string]string{"accountId": "5b10ac8d82e05b22cc7d4ef5"},
+				"updated":     "2021-06-01T12:00:00.000+0200",
+			},
+		}
+		if recorded != nil {
+			issue["properties"] = map[string]interface{}{jiraBodyProperty: recorded}
+		}
+		encoded, err := json.Marshal(issue)
+		Expect(err).NotTo(HaveOccurred())
+		return string(encoded)
+	}
+
+	Context("on Jira Cloud", func() {
+		const apiPath = "/rest/api/3/"
+		var t *Jira
+
+		BeforeEach(func() {
+			t = NewJira(nil, api.server.URL, "user@example.com", "api-token", JiraOptions{}, logr.Discard())
+		})
+
+		Context("Get", func() {
+			It("converts the issue", func() {
+				api.reply("GET", apiPath+"issue/PROJ-12", http.StatusOK, jiraIssueJSON("PROJ-12", jiraDoneCategory, nil, nil))
+
+				issue, err := t.Get(ctx, "PROJ", 12)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue.Number).To(Equal(12))
+				Expect(issue.Key).To(Equal("PROJ-12"))
+				Expect(issue.NodeID).To(Equal("10001"))
+				Expect(issue.Title).To(Equal("summary of PROJ-12"))
+				Expect(issue.State).To(Equal(StateClosed))
+				Expect(issue.Labels).To(Equal([]string{"bug"}))
+				Expect(issue.Assignees).To(Equal([]string{"5b10ac8d82e05b22cc7d4ef5"}))
+				Expect(issue.HTMLURL).To(Equal(api.server.URL + "/browse/PROJ-12"))
+				Expect(issue.UpdatedAt.Equal(time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC))).To(BeTrue())
+				req := api.lastRequest("GET", apiPath+"issue/PROJ-12")
+				Expect(req.Header.Get("Authorization")).To(Equal("Basic dXNlckBleGFtcGxlLmNvbTphcGktdG9rZW4="))
+				Expect(req.Query.Get("properties")).To(Equal(jiraBodyProperty))
+			})
+
+			It("reads back the recorded Markdown while the description is unchanged", func() {
+				description := markdownToADF("**hello**")
+				encoded, _ := json.Marshal(description)
+				recorded := &jiraBody{Body: "**hello**", DescriptionHash: descriptionHash(encoded)}
+				api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", "new", description, recorded))
+
+				issue, err := t.Get(ctx, "PROJ", 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue.Body).To(Equal("**hello**"))
+				Expect(issue.State).To(Equal(StateOpen))
+			})
+
+			It("reads the text of a description edited in Jira", func() {
+				recorded := &jiraBody{Body: "**hello**", DescriptionHash: "stale"}
+				api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", "new", markdownToADF("hello *again*"), recorded))
+
+				issue, err := t.Get(ctx, "PROJ", 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue.Body).To(Equal("hello again"))
+			})
+
+			It("returns nil for an issue that does not exist or moved to another project", func() {
+				api.reply("GET", apiPath+"issue/PROJ-2", http.StatusOK, jiraIssueJSON("OTHER-5", "new", nil, nil))
+
+				issue, err := t.Get(ctx, "PROJ", 1)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue).To(BeNil())
+				issue, err = t.Get(ctx, "PROJ", 2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue).To(BeNil())
+			})
+		})
+
+		It("pages through the search with the next page token", func() {
+			api.handle("POST", apiPath+"search/jql", func(w http.ResponseWriter, r *http.Request) {
+				var request map[string]interface{}
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				if request["nextPageToken"] == nil {
+					fmt.Fprintf(w, `{"issues": [%s, %s], "nextPageToken": "page-2"}`,
+						jiraIssueJSON("PROJ-4", "new", nil, nil), jiraIssueJSON("PROJ-3", "new", nil, nil))
+					return
+				}
+				fmt.Fprintf(w, `{"issues": [%s], "isLast": true}`, jiraIssueJSON("PROJ-2", "new", nil, nil))
+			})
+
+			list, err := t.List(ctx, "PROJ", ListOptions{State: StateOpen, PageSize: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Issues).To(HaveLen(3))
+			Expect(list.Issues[2].Number).To(Equal(2))
+			Expect(list.Truncated).To(BeFalse())
+			request := api.lastRequest("POST", apiPath+"search/jql").json()
+			Expect(request).To(HaveKeyWithValue("jql", `project = "PROJ" AND statusCategory != Done ORDER BY created DESC`))
+			Expect(request).To(HaveKeyWithValue("maxResults", BeEquivalentTo(2)))
+			Expect(request).To(HaveKeyWithValue("nextPageToken", "page-2"))
+		})
+
+		It("creates issues with the first assignee and records the Markdown", func() {
+			var property jiraBody
+			api.reply("POST", apiPath+"issue", http.StatusCreated, `{"key": "PROJ-7"}`)
+			api.reply("PUT", apiPath+"issue/PROJ-7/properties/"+jiraBodyProperty, http.StatusCreated, nil)
+			api.handle("GET", apiPath+"issue/PROJ-7", func(w http.ResponseWriter, r *http.Request) {
+				if put := api.lastRequest("PUT", apiPath+"issue/PROJ-7/properties/"+jiraBodyProperty); put != nil {
+					Expect(json.Unmarshal([]byte(put.Body), &property)).To(Succeed())
+				}
+				fmt.Fprint(w, jiraIssueJSON("PROJ-7", "new", markdownToADF("# hi"), &property))
+			})
+
+			issue, err := t.Create(ctx, "PROJ", IssueRequest{Title: "new", Body: "# hi", Assignees: []string{"first", "second"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Number).To(Equal(7))
+			Expect(issue.Body).To(Equal("# hi"))
+			fields := api.lastRequest("POST", apiPath+"issue").json()["fields"]
+			Expect(fields).To(HaveKeyWithValue("project", HaveKeyWithValue("key", "PROJ")))
+			Expect(fields).To(HaveKeyWithValue("issuetype", HaveKeyWithValue("name", "Task")))
+			Expect(fields).To(HaveKeyWithValue("summary", "new"))
+			Expect(fields).To(HaveKeyWithValue("description", HaveKeyWithValue("type", "doc")))
+			Expect(fields).To(HaveKeyWithValue("labels", BeEmpty()))
+			Expect(fields).To(HaveKeyWithValue("assignee", Equal(map[string]interface{}{"accountId": "first"})))
+			Expect(property.Body).To(Equal("# hi"))
+		})
+
+		It("clears the description with null", func() {
+			body := ""
+			api.reply("PUT", apiPath+"issue/PROJ-1", http.StatusNoContent, nil)
+			api.reply("PUT", apiPath+"issue/PROJ-1/properties/"+jiraBodyProperty, http.StatusOK, nil)
+			api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", "new", nil, nil))
+
+			_, err := t.Update(ctx, "PROJ", 1, IssueUpdate{Body: &body})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(api.lastRequest("PUT", apiPath+"issue/PROJ-1").json()).To(Equal(map[string]interface{}{"fields": map[string]interface{}{"description": nil}}))
+		})
+
+		Context("transitions", func() {
+			BeforeEach(func() {
+				api.reply("GET", apiPath+"issue/PROJ-1/transitions", http.StatusOK, `{"transitions": [
+					{"id": "11", "name": "Start", "to": {"name": "In Progress"}},
+					{"id": "31", "name": "Resolve", "to": {"name": "Done"}}]}`)
+				api.reply("POST", apiPath+"issue/PROJ-1/transitions", http.StatusNoContent, nil)
+				api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", jiraDoneCategory, nil, nil))
+			})
+
+			It("closes with the transition leading to the close status", func() {
+				issue, err := t.Close(ctx, "PROJ", 1, "completed")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(issue.State).To(Equal(StateClosed))
+				Expect(api.lastRequest("POST", apiPath+"issue/PROJ-1/transitions").json()).To(Equal(map[string]interface{}{"transition": map[string]interface{}{"id": "31"}}))
+			})
+
+			It("names the available transitions if none matches", func() {
+				_, err := t.Reopen(ctx, "PROJ", 1)
+				Expect(err).To(MatchError(`jira issue PROJ-1 has no transition "To Do", available: Start, Resolve`))
+				Expect(api.requestCount("POST", apiPath+"issue/PROJ-1/transitions")).To(BeZero())
+			})
+		})
+
+		It("accepts only the first assignee, if assignable", func() {
+			api.reply("GET", apiPath+"user/assignable/search", http.StatusOK, `[{"accountId": "first"}]`)
+
+			valid, rejected, err := t.ValidateAssignees(ctx, "PROJ", []string{"first", "second"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(valid).To(Equal([]string{"first"}))
+			Expect(rejected).To(Equal([]string{"second"}))
+			query := api.lastRequest("GET", apiPath+"user/assignable/search").Query
+			Expect(query.Get("project")).To(Equal("PROJ"))
+			Expect(query.Get("accountId")).To(Equal("first"))
+		})
+
+		It("unassigns the issue with null", func() {
+			api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", "new", nil, nil))
+			api.reply("PUT", apiPath+"issue/PROJ-1/assignee", http.StatusNoContent, nil)
+
+			Expect(t.RemoveAssignees(ctx, "PROJ", 1, []string{"someone"})).To(Succeed())
+			Expect(api.requestCount("PUT", apiPath+"issue/PROJ-1/assignee")).To(BeZero())
+			Expect(t.RemoveAssignees(ctx, "PROJ", 1, []string{"5b10ac8d82e05b22cc7d4ef5"})).To(Succeed())
+			Expect(api.lastRequest("PUT", apiPath+"issue/PROJ-1/assignee").json()).To(Equal(map[string]interface{}{"accountId": nil}))
+		})
+
+		It("does not support locking", func() {
+			Expect(errors.Is(t.Lock(ctx, "PROJ", 1), ErrNotSupported)).To(BeTrue())
+		})
+	})
+
+	Context("on Jira Server", func() {
+		const apiPath = "/rest/api/2/"
+		var t *Jira
+
+		BeforeEach(func() {
+			t = NewJira(nil, api.server.URL+"/", "", "personal-token", JiraOptions{Server: true, CloseTransition: "Closed"}, logr.Discard())
+		})
+
+		It("reads wiki markup descriptions with a personal access token", func() {
+			api.reply("GET", apiPath+"issue/PROJ-1", http.StatusOK, jiraIssueJSON("PROJ-1", "new", "h1. Title", nil))
+
+			issue, err := t.Get(ctx, "PROJ", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(issue.Body).To(Equal("h1. Title"))
+			Expect(api.lastRequest("GET", apiPath+"issue/PROJ-1").Header.Get("Authorization")).To(Equal("Bearer personal-token"))
+		})
+
+		It("pages through the search with an offset", func() {
+			api.handle("POST", apiPath+"search", func(w http.ResponseWriter, r *http.Request) {
+				var request map[string]interface{}
+				Expect(json.NewDecoder(r.Body).Decode(&request)).To(Succeed())
+				if request["startAt"] == float64(0) {
+					fmt.Fprintf(w, `{"issues": [%s, %s], "total": 3}`, jiraIssueJSON("PROJ-3", "new", nil, nil), jiraIssueJSON("PROJ-2", "new", nil, nil))
+					return
+				}
+				fmt.Fprintf(w, `{"issues": [%s], "total": 3}`, jiraIssueJSON("PROJ-1", "new", nil, nil))
+			})
+
+			list, err := t.List(ctx, "PROJ", ListOptions{PageSize: 2, MaxIssues: 2})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list.Issues).To(HaveLen(2))
+			Expect(list.Truncated).To(BeTrue())
+			Expect(api.requestCount("POST", apiPath+"search")).To(Equal(1))
+		})
+
+		It("writes descriptions and comments as wiki markup", func() {
+			api.reply("POST", apiPath+"issue/PROJ-1/comment", http.StatusCreated, `{}`)
+
+			Expect(t.Comment(ctx, "PROJ", 1, "**closed** by the operator")).To(Succeed())
+			Expect(api.lastRequest("POST", apiPath+"issue/PROJ-1/comment").json()).To(Equal(map[string]interface{}{"body": "*closed* by the operator"}))
+		})
+
+		It("assigns by username", func() {
+			api.reply("PUT", apiPath+"issue/PROJ-1/assignee", http.StatusNoContent, nil)
+
+			Expect(t.AddAssignees(ctx, "PROJ", 1, []string{"jdoe"})).To(Succeed())
+			Expect(api.lastRequest("PUT", apiPath+"issue/PROJ-1/assignee").json()).To(Equal(map[string]interface{}{"name": "jdoe"}))
+		})
+
+		It("can not list labels", func() {
+			_, err := t.ListLabels(ctx, "PROJ")
+			Expect(errors.Is(err, ErrNotSupported)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,330 @@
+package tracker
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the Markdown converters cover what issue descriptions usually contain: headings, paragraphs, emphasis, inline code,
+// links, bullet and numbered lists, block quotes, fenced code blocks and horizontal rules. HTML comments (such as the
+// ownership marker) are dropped, anything else is passed through as text
+
+var (
+	htmlCommentRegexp = regexp.MustCompile(`(?s)<!--.*?-->`)
+	headingRegexp     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletRegexp      = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberedRegexp    = regexp.MustCompile(`^(\s*)\d+[.)]\s+(.*)$`)
+	ruleRegexp        = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+)
+
+type blockKind int
+
+const (
+	paragraphBlock blockKind = iota
+	headingBlock
+	bulletBlock
+	numberedBlock
+	quoteBlock
+	codeBlock
+	ruleBlock
+)
+
+// mdBlock is a block of a Markdown document. List items are one block each
+type mdBlock struct {
+	kind     blockKind
+	level    int    // of a heading, or the nesting of a list item (0 for top level)
+	text     string // inline Markdown, or the verbatim content of a code block
+	language string // of a code block
+}
+
+func parseBlocks(markdown string) []mdBlock {
+	markdown = htmlCommentRegexp.ReplaceAllString(strings.Replace(markdown, "\r\n", "\n", -1), "")
+	var blocks []mdBlock
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			blocks = append(blocks, mdBlock{kind: paragraphBlock, text: strings.Join(paragraph, " ")})
+			paragraph = nil
+		}
+	}
+	lines := strings.Split(markdown, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			block := mdBlock{kind: codeBlock, language: strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))}
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			block.text = strings.Join(code, "\n")
+			blocks = append(blocks, block)
+			continue
+		}
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		if ruleRegexp.MatchString(line) {
+			flush()
+			blocks = append(blocks, mdBlock{kind: ruleBlock})
+			continue
+		}
+		if match := headingRegexp.FindStringSubmatch(trimmed); match != nil {
+			flush()
+			blocks = append(blocks, mdBlock{kind: headingBlock, level: len(match[1]), text: match[2]})
+			continue
+		}
+		if match := bulletRegexp.FindStringSubmatch(line); match != nil {
+			flush()
+			blocks = append(blocks, mdBlock{kind: bulletBlock, level: len(match[1]) / 2, text: match[2]})
+			continue
+		}
+		if match := numberedRegexp.FindStringSubmatch(line); match != nil {
+			flush()
+			blocks = append(blocks, mdBlock{kind: numberedBlock, level: len(match[1]) / 2, text: match[2]})
+			continue
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			flush()
+			blocks = append(blocks, mdBlock{kind: quoteBlock, text: strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))})
+			continue
+		}
+		paragraph = append(paragraph, trimmed)
+	}
+	flush()
+	return blocks
+}
+
+// mdSpan is a run of text with the same formatting
+type mdSpan struct {
+	text   string
+	bold   bool
+	italic bool
+	code   bool
+	href   string // the span is a link
+}
+
+/*
+splits inline Markdown into spans. Emphasis does not nest, link texts are plain
+*/
+func parseInline(text string) []mdSpan {
+	var spans []mdSpan
+	var plain strings.Builder
+	flush := func() {
+		if plain.Len() > 0 {
+			spans = append(spans, mdSpan{text: plain.String()})
+			plain.Reset()
+		}
+	}
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, "`"):
+			if end := strings.Index(rest[1:], "`"); end > 0 {
+				flush()
+				spans = append(spans, mdSpan{text: rest[1 : end+1], code: true})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "**") || strings.HasPrefix(rest, "__"):
+			if end := strings.Index(rest[2:], rest[:2]); end > 0 {
+				flush()
+				spans = append(spans, mdSpan{text: rest[2 : end+2], bold: true})
+				i += end + 4
+				continue
+			}
+		case strings.HasPrefix(rest, "*") || strings.HasPrefix(rest, "_"):
+			if end := strings.Index(rest[1:], rest[:1]); end > 0 {
+				flush()
+				spans = append(spans, mdSpan{text: rest[1 : end+1], italic: true})
+				i += end + 2
+				continue
+			}
+		case strings.HasPrefix(rest, "["):
+			if closing := strings.Index(rest, "]("); closing > 0 {
+				if end := strings.Index(rest[closing:], ")"); end > 0 {
+					flush()
+					spans = append(spans, mdSpan{text: rest[1:closing], href: rest[closing+2 : closing+end]})
+					i += closing + end + 1
+					continue
+				}
+			}
+		}
+		plain.WriteByte(text[i])
+		i++
+	}
+	flush()
+	return spans
+}
+
+/*
+converts Markdown to Jira wiki markup (Jira Server / Data Center)
+*/
+func markdownToWiki(markdown string) string {
+	var out []string
+	for _, block := range parseBlocks(markdown) {
+		switch block.kind {
+		case headingBlock:
+			out = append(out, "h"+strconv.Itoa(block.level)+". "+inlineToWiki(block.text))
+		case bulletBlock:
+			out = append(out, strings.Repeat("*", block.level+1)+" "+inlineToWiki(block.text))
+		case numberedBlock:
+			out = append(out, strings.Repeat("#", block.level+1)+" "+inlineToWiki(block.text))
+		case quoteBlock:
+			out = append(out, "bq. "+inlineToWiki(block.text))
+		case codeBlock:
+			open := "{code}"
+			if block.language != "" {
+				open = "{code:" + block.language + "}"
+			}
+			out = append(out, open+"\n"+block.text+"\n{code}")
+		case ruleBlock:
+			out = append(out, "----")
+		default:
+			out = append(out, inlineToWiki(block.text))
+		}
+	}
+	/* list items of the same list are on consecutive lines, every other block is separated by a blank line */
+	var wiki strings.Builder
+	for i, line := range out {
+		if i > 0 {
+			wiki.WriteString("\n")
+			if !(isWikiListLine(out[i-1]) && isWikiListLine(line)) {
+				wiki.WriteString("\n")
+			}
+		}
+		wiki.WriteString(line)
+	}
+	return wiki.String()
+}
+
+func isWikiListLine(line string) bool {
+	return strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "**") || strings.HasPrefix(line, "##")
+}
+
+func inlineToWiki(text string) string {
+	var wiki strings.Builder
+	for _, span := range parseInline(text) {
+		switch {
+		case span.code:
+			wiki.WriteString("{{" + span.text + "}}")
+		case span.href != "":
+			wiki.WriteString("[" + span.text + "|" + span.href + "]")
+		case span.bold:
+			wiki.WriteString("*" + span.text + "*")
+		case span.italic:
+			wiki.WriteString("_" + span.text + "_")
+		default:
+			wiki.WriteString(span.text)
+		}
+	}
+	return wiki.String()
+}
+
+// adfNode is a node of the Atlassian Document Format (Jira Cloud)
+type adfNode struct {
+	Type    string                 `json:"type"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Content []*adfNode             `json:"content,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Marks   []*adfNode             `json:"marks,omitempty"`
+	Version int                    `json:"version,omitempty"` // of the doc node
+}
+
+/*
+converts Markdown to an Atlassian Document Format document. Nested list items are flattened into their list
+*/
+func markdownToADF(markdown string) *adfNode {
+	doc := &adfNode{Type: "doc", Version: 1}
+	var list *adfNode
+	for _, block := range parseBlocks(markdown) {
+		if block.kind == bulletBlock || block.kind == numberedBlock {
+			listType := "bulletList"
+			if block.kind == numberedBlock {
+				listType = "orderedList"
+			}
+			if list == nil || list.Type != listType {
+				list = &adfNode{Type: listType}
+				doc.Content = append(doc.Content, list)
+			}
+			list.Content = append(list.Content, &adfNode{Type: "listItem", Content: []*adfNode{inlineToADF("paragraph", block.text)}})
+			continue
+		}
+		list = nil
+		switch block.kind {
+		case headingBlock:
+			heading := inlineToADF("heading", block.text)
+			heading.Attrs = map[string]interface{}{"level": block.level}
+			doc.Content = append(doc.Content, heading)
+		case quoteBlock:
+			doc.Content = append(doc.Content, &adfNode{Type: "blockquote", Content: []*adfNode{inlineToADF("paragraph", block.text)}})
+		case codeBlock:
+			code := &adfNode{Type: "codeBlock"}
+			if block.language != "" {
+				code.Attrs = map[string]interface{}{"language": block.language}
+			}
+			if block.text != "" {
+				code.Content = []*adfNode{{Type: "text", Text: block.text}}
+			}
+			doc.Content = append(doc.Content, code)
+		case ruleBlock:
+			doc.Content = append(doc.Content, &adfNode{Type: "rule"})
+		default:
+			doc.Content = append(doc.Content, inlineToADF("paragraph", block.text))
+		}
+	}
+	return doc
+}
+
+func inlineToADF(nodeType, text string) *adfNode {
+	node := &adfNode{Type: nodeType}
+	for _, span := range parseInline(text) {
+		textNode := &adfNode{Type: "text", Text: span.text}
+		switch {
+		case span.code:
+			textNode.Marks = []*adfNode{{Type: "code"}}
+		case span.href != "":
+			textNode.Marks = []*adfNode{{Type: "link", Attrs: map[string]interface{}{"href": span.href}}}
+		case span.bold:
+			textNode.Marks = []*adfNode{{Type: "strong"}}
+		case span.italic:
+			textNode.Marks = []*adfNode{{Type: "em"}}
+		}
+		node.Content = append(node.Content, textNode)
+	}
+	return node
+}
+
+/*
+the text of an Atlassian Document Format document, blocks separated by blank lines
+*/
+func adfText(node *adfNode) string {
+	if node == nil {
+		return ""
+	}
+	if node.Type == "text" {
+		return node.Text
+	}
+	var parts []string
+	var inline strings.Builder
+	for _, child := range node.Content {
+		if child.Type == "text" || child.Type == "hardBreak" || child.Type == "mention" || child.Type == "emoji" {
+			inline.WriteString(adfText(child))
+			continue
+		}
+		if text := adfText(child); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if inline.Len() > 0 {
+		parts = append([]string{inline.String()}, parts...)
+	}
+	separator := "\n\n"
+	if node.Type == "bulletList" || node.Type == "orderedList" || node.Type == "listItem" {
+		separator = "\n"
+	}
+	return strings.Join(parts, separator)
+}
@@ -0,0 +1,73 @@
+package tracker
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Markdown", func() {
+	table.DescribeTable("to Jira wiki markup",
+		func(markdown, wiki string) {
+			Expect(markdownToWiki(markdown)).To(Equal(wiki))
+		},
+		table.Entry("headings", "# Title\n### Section ##", "h1. Title\n\nh3. Section"),
+		table.Entry("paragraphs", "first\nline\n\nsecond", "first line\n\nsecond"),
+		table.Entry("emphasis, code and links", "**bold** and *italic* with `code` [docs](https://example.com)",
+			"*bold* and _italic_ with {{code}} [docs|https://example.com]"),
+		table.Entry("bullet lists", "- one\n* two\n  - nested", "* one\n* two\n** nested"),
+		table.Entry("numbered lists", "1. one\n2) two", "# one\n# two"),
+		table.Entry("lists between other blocks", "intro\n- one\n- two\n\noutro", "intro\n\n* one\n* two\n\noutro"),
+		table.Entry("block quotes", "> quoted", "bq. quoted"),
+		table.Entry("fenced code", "```go\nx := *y\n\n# not a heading\n```", "{code:go}\nx := *y\n\n# not a heading\n{code}"),
+		table.Entry("fenced code without language", "```\nplain\n```", "{code}\nplain\n{code}"),
+		table.Entry("horizontal rules", "above\n\n---\n\nbelow", "above\n\n----\n\nbelow"),
+		table.Entry("the ownership marker", "text\n\n<!-- githubissue-operator: default/name -->", "text"),
+		table.Entry("unclosed emphasis", "2 * 3 and a_b", "2 * 3 and a_b"),
+		table.Entry("Windows line endings", "one\r\n\r\ntwo", "one\n\ntwo"),
+		table.Entry("nothing", "", ""),
+	)
+
+	table.DescribeTable("to Atlassian Document Format",
+		func(markdown, adf string) {
+			encoded, err := json.Marshal(markdownToADF(markdown))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encoded).To(MatchJSON(adf))
+		},
+		table.Entry("nothing", "", `{"type": "doc", "version": 1}`),
+		table.Entry("headings", "## Title", `{"type": "doc", "version": 1, "content": [
+			{"type": "heading", "attrs": {"level": 2}, "content": [{"type": "text", "text": "Title"}]}]}`),
+		table.Entry("marks", "**bold** `code` [docs](https://example.com) _em_", `{"type": "doc", "version": 1, "content": [
+			{"type": "paragraph", "content": [
+				{"type": "text", "text": "bold", "marks": [{"type": "strong"}]},
+				{"type": "text", "text": " "},
+				{"type": "text", "text": "code", "marks": [{"type": "code"}]},
+				{"type": "text", "text": " "},
+				{"type": "text", "text": "docs", "marks": [{"type": "link", "attrs": {"href": "https://example.com"}}]},
+				{"type": "text", "text": " "},
+				{"type": "text", "text": "em", "marks": [{"type": "em"}]}]}]}`),
+		table.Entry("lists, nested items flattened", "- one\n  - nested\n1. first", `{"type": "doc", "version": 1, "content": [
+			{"type": "bulletList", "content": [
+				{"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "one"}]}]},
+				{"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "nested"}]}]}]},
+			{"type": "orderedList", "content": [
+				{"type": "listItem", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "first"}]}]}]}]}`),
+		table.Entry("quotes, code and rules", "> quoted\n\n```sh\nls\n```\n\n```\n```\n\n***", `{"type": "doc", "version": 1, "content": [
+			{"type": "blockquote", "content": [{"type": "paragraph", "content": [{"type": "text", "text": "quoted"}]}]},
+			{"type": "codeBlock", "attrs": {"language": "sh"}, "content": [{"type": "text", "text": "ls"}]},
+			{"type": "codeBlock"},
+			{"type": "rule"}]}`),
+	)
+
+	table.DescribeTable("text of Atlassian Document Format",
+		func(markdown, text string) {
+			Expect(adfText(markdownToADF(markdown))).To(Equal(text))
+		},
+		table.Entry("paragraphs", "first *line*\n\nsecond", "first line\n\nsecond"),
+		table.Entry("lists", "# Title\n\n- one\n- two\n\noutro", "Title\n\none\ntwo\n\noutro"),
+		table.Entry("code", "```\na\nb\n```", "a\nb"),
+		table.Entry("nothing", "", ""),
+	)
+})
@@ -0,0 +1,106 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNotSupported is returned for what the tracker has no equivalent of
+var ErrNotSupported = errors.New("not supported by this issue tracker")
+
+// maxErrorBody bounds how much of an error response ends up in the error message
+const maxErrorBody = 512
+
+// restClient sends JSON requests to the REST API of the trackers without a Go client of their own
+type restClient struct {
+	httpClient *http.Client
+	// baseURL of the API, ending with a slash
+	baseURL string
+	// header is sent with every request (the credentials)
+	header http.Header
+}
+
+func newRESTClient(httpClient *http.Client, baseURL string, header http.Header) *restClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &restClient{httpClient: httpClient, baseURL: baseURL, header: header}
+}
+
+/*
+sends body (if not nil) as JSON to path (relative to the base URL) and decodes the answer into out (if not nil).
+Answers outside 2xx are returned as StatusError, with the start of the response body as message
+*/
+func (c *restClient) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return resp, &StatusError{StatusCode: resp.StatusCode, Err: fmt.Errorf("%s %s: %s: %s", method, req.URL.Path, resp.Status, bytes.TrimSpace(message))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return resp, nil
+	}
+	return resp, json.NewDecoder(resp.Body).Decode(out)
+}
+
+/*
+whether err is an answer with one of the status codes
+*/
+func hasStatus(err error, codes ...int) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	for _, code := range codes {
+		if statusErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+whether the Link header of resp points to a next page
+*/
+func hasNextPage(resp *http.Response) bool {
+	return strings.Contains(resp.Header.Get("Link"), `rel="next"`)
+}
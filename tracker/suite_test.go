@@ -0,0 +1,143 @@
+package tracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+)
+
+// The trackers are tested against fakeAPI, without a cluster
+
+func TestTrackers(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"Tracker Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+// fakeAPI answers the requests of a tracker with canned answers, routed by method and (escaped) path, and records every
+// request so the specs can check what the tracker sent. Unrouted requests are answered 404
+type fakeAPI struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	routes   map[string]http.HandlerFunc // by "METHOD path"
+	requests []*recordedRequest
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+func newFakeAPI() *fakeAPI {
+	f := &fakeAPI{routes: map[string]http.HandlerFunc{}}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	f.mu.Lock()
+	path := r.URL.EscapedPath()
+	f.requests = append(f.requests, &recordedRequest{Method: r.Method, Path: path, Query: r.URL.Query(), Header: r.Header, Body: string(body)})
+	handler := f.routes[r.Method+" "+path]
+	f.mu.Unlock()
+	if handler == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+		return
+	}
+	handler(w, r)
+}
+
+/*
+serves method and path with handler
+*/
+func (f *fakeAPI) handle(method, path string, handler http.HandlerFunc) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[method+" "+path] = handler
+}
+
+/*
+answers method and path with status and body, encoded as JSON unless it is a string
+*/
+func (f *fakeAPI) reply(method, path string, status int, body interface{}) {
+	f.handle(method, path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if encoded, ok := body.(string); ok {
+			w.Write([]byte(encoded))
+			return
+		}
+		if body != nil {
+			json.NewEncoder(w).Encode(body)
+		}
+	})
+}
+
+/*
+the last request sent with method to path, nil if none
+*/
+func (f *fakeAPI) lastRequest(method, path string) *recordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.requests) - 1; i >= 0; i-- {
+		if f.requests[i].Method == method && f.requests[i].Path == path {
+			return f.requests[i]
+		}
+	}
+	return nil
+}
+
+/*
+the number of requests sent with method to path
+*/
+func (f *fakeAPI) requestCount(method, path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, req := range f.requests {
+		if req.Method == method && req.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+the JSON body of the request, decoded into a map
+*/
+func (r *recordedRequest) json() map[string]interface{} {
+	decoded := map[string]interface{}{}
+	Expect(json.Unmarshal([]byte(r.Body), &decoded)).To(Succeed())
+	return decoded
+}
+
+func (f *fakeAPI) close() {
+	f.server.Close()
+}
+
+func mustParseTime(value string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, value)
+	Expect(err).NotTo(HaveOccurred())
+	return parsed
+}
@@ -0,0 +1,118 @@
+// Package tracker abstracts the issue tracker GithubIssue objects are synced with, so the reconciler does not depend
+// on a particular API and other backends (or fakes) can be plugged in.
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Issue is an issue as the reconciler sees it, whatever tracker it lives in
+type Issue struct {
+	Number int
+	// NodeID is the tracker's global id of the issue, if it has one
+	NodeID string
+	// Key is the issue's name on trackers naming issues by key rather than number (Jira: PROJECT-123)
+	Key   string
+	Title string
+	Body  string
+	// State is StateOpen or StateClosed
+	State     string
+	Labels    []string
+	Assignees []string
+	// Milestone is the number of the issue's milestone, 0 if none
+	Milestone int
+	Locked    bool
+	HTMLURL   string
+	UpdatedAt time.Time
+	// PullRequest is true for pull (merge) requests, which some trackers list as issues
+	PullRequest bool
+}
+
+// IssueRequest is a new issue
+type IssueRequest struct {
+	Title     string
+	Body      string
+	Labels    []string
+	Assignees []string
+	// Milestone is the number of the milestone, 0 for none
+	Milestone int
+}
+
+// IssueUpdate is an edit of an issue. Nil fields are left as they are
+type IssueUpdate struct {
+	Title *string
+	Body  *string
+	// Milestone is the number of the new milestone, 0 removes the milestone
+	Milestone *int
+}
+
+// ListOptions controls how List pages through a repository
+type ListOptions struct {
+	// State is StateOpen, StateClosed or empty for all issues
+	State string
+	// Since lists only the issues updated at or after it, zero lists all issues
+	Since     time.Time
+	PageSize  int
+	MaxIssues int
+	// ETag of a previous listing with the same options. Trackers supporting conditional requests answer
+	// IssueList.NotModified if nothing changed since
+	ETag string
+}
+
+// IssueList is the result of List
+type IssueList struct {
+	Issues []*Issue
+	// Truncated is true if the repository has more issues than ListOptions.MaxIssues
+	Truncated bool
+	// ETag to send with the next listing, empty if the tracker does not support conditional requests
+	ETag        string
+	NotModified bool
+}
+
+// IssueTracker manages the issues of the repositories (projects) of a tracker. repo is the tracker's path of the
+// repository, e.g. owner/repo on github. Implementations are bound to a set of credentials
+type IssueTracker interface {
+	// Get returns nil (and a nil error) if the issue does not exist (anymore)
+	Get(ctx context.Context, repo string, number int) (*Issue, error)
+	List(ctx context.Context, repo string, opts ListOptions) (*IssueList, error)
+	Create(ctx context.Context, repo string, issue IssueRequest) (*Issue, error)
+	Update(ctx context.Context, repo string, number int, update IssueUpdate) (*Issue, error)
+	// Close closes the issue. reason (completed / not_planned) is a hint trackers may ignore
+	Close(ctx context.Context, repo string, number int, reason string) (*Issue, error)
+	Reopen(ctx context.Context, repo string, number int) (*Issue, error)
+	Lock(ctx context.Context, repo string, number int) error
+	Comment(ctx context.Context, repo string, number int, body string) error
+	// SetLabels replaces the labels of the issue
+	SetLabels(ctx context.Context, repo string, number int, labels []string) error
+	ListLabels(ctx context.Context, repo string) ([]string, error)
+	// ValidateAssignees splits logins into the ones that can be assigned to issues of the repo and the ones the
+	// tracker would reject
+	ValidateAssignees(ctx context.Context, repo string, logins []string) (valid, rejected []string, err error)
+	AddAssignees(ctx context.Context, repo string, number int, logins []string) error
+	RemoveAssignees(ctx context.Context, repo string, number int, logins []string) error
+}
+
+// StatusError is an answer of the tracker with an unexpected HTTP status code. Err is the error of the tracker's
+// client, if any
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("unexpected response status %d", e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}